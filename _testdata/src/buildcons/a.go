@@ -0,0 +1,7 @@
+package buildcons
+
+import (
+	"sort"
+)
+
+var _ = sort.Strings
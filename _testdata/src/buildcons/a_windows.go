@@ -0,0 +1,7 @@
+package buildcons
+
+import (
+	"sort"
+
+	"golang.org/x/sys/windows"
+)
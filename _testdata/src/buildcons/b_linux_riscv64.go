@@ -0,0 +1,3 @@
+package buildcons
+
+import "github.com/riscv/vector"
@@ -0,0 +1,5 @@
+//go:build appengine || (linux && !cgo)
+
+package buildcons
+
+import "google.golang.org/appengine"
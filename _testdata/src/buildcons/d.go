@@ -0,0 +1,6 @@
+// +build darwin,amd64 windows
+// +build !nacl
+
+package buildcons
+
+import "golang.org/x/sys/windows"
@@ -0,0 +1,5 @@
+// +build integration
+
+package buildcons
+
+import "github.com/integration/harness"
@@ -0,0 +1,5 @@
+// +build gc
+
+package buildcons
+
+import "github.com/gc/asm"
@@ -0,0 +1,7 @@
+package gae
+
+import (
+	"sort"
+)
+
+var _ = sort.Strings
@@ -0,0 +1,13 @@
+// +build appengine
+
+package gae
+
+import (
+	"appengine"
+	"appengine/datastore"
+)
+
+var (
+	_ = appengine.NewContext
+	_ = datastore.NewKey
+)
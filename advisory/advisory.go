@@ -0,0 +1,255 @@
+// Package advisory checks locks against a local database of security
+// advisories, without calling out to any external service.
+//
+// A database is made up of JSON files, each listing advisories. An advisory
+// names a project root, and the versions and revisions of it that are
+// affected:
+//
+//  {"advisories": [{
+//      "id": "GPS-2017-0001",
+//      "project": "github.com/example/lib",
+//      "versions": ">=1.0.0, <1.2.3",
+//      "revisions": ["2c7ab9a3f3ff4bbea5a6d0b2f3e5a9f6e6b1a2c3"],
+//      "summary": "Unbounded allocation when decoding headers."
+//  }]}
+//
+// Fields other than these are ignored, so databases can carry additional
+// information.
+//
+// Only JSON is understood. Files with a .toml extension are rejected, rather
+// than skipped, so that a database written in TOML can't silently appear to
+// be empty.
+package advisory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sdboyer/gps"
+)
+
+// An Advisory describes a problem affecting some versions of a project.
+type Advisory struct {
+	// ID uniquely identifies the advisory within the database.
+	ID string
+	// ProjectRoot is the project that is affected.
+	ProjectRoot gps.ProjectRoot
+	// Affected are the constraints admitting the affected versions. A version
+	// is affected if any of them matches it.
+	Affected []gps.Constraint
+	// Summary is a short, human-readable description of the problem.
+	Summary string
+}
+
+// Affects indicates whether the advisory applies to the given LockedProject.
+//
+// The check uses Constraint.Matches: a revision constraint matches a locked
+// project at that revision, whatever version is paired with it, and a version
+// constraint matches the locked project's version, if it has one.
+func (a Advisory) Affects(lp gps.LockedProject) bool {
+	if lp.Ident().ProjectRoot != a.ProjectRoot {
+		return false
+	}
+
+	v := lp.Version()
+	for _, c := range a.Affected {
+		if c.Matches(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// A Match pairs a LockedProject with the advisories affecting it.
+type Match struct {
+	Project    gps.LockedProject
+	Advisories []Advisory
+}
+
+// DB is a database of advisories, indexed by ProjectRoot.
+type DB struct {
+	byRoot map[gps.ProjectRoot][]Advisory
+	ids    map[string]string // advisory ID to the file it came from
+}
+
+// NewDB creates a DB containing the provided advisories.
+func NewDB(advs ...Advisory) (*DB, error) {
+	db := &DB{
+		byRoot: make(map[gps.ProjectRoot][]Advisory),
+		ids:    make(map[string]string),
+	}
+
+	for _, a := range advs {
+		if err := db.add(a, ""); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Load reads a DB from the file or directory at the given path. Directories
+// are searched recursively for files with a .json extension; finding a file
+// with a .toml extension is an error.
+func Load(path string) (*DB, error) {
+	db, _ := NewDB()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		if err = db.loadFile(path); err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".json", ".toml":
+			return db.loadFile(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) loadFile(path string) error {
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		return fmt.Errorf("%s: TOML advisory files are not supported; only JSON is", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	raws, err := readJSON(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	for _, raw := range raws {
+		a, err := raw.toAdvisory()
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		if err = db.add(a, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) add(a Advisory, from string) error {
+	if a.ID == "" {
+		return fmt.Errorf("advisory for %s has no ID", a.ProjectRoot)
+	}
+	if a.ProjectRoot == "" {
+		return fmt.Errorf("advisory %s names no project", a.ID)
+	}
+	if len(a.Affected) == 0 {
+		return fmt.Errorf("advisory %s specifies no affected versions or revisions", a.ID)
+	}
+	if prev, has := db.ids[a.ID]; has {
+		if prev == "" && from == "" {
+			return fmt.Errorf("advisory %s is defined more than once", a.ID)
+		}
+		return fmt.Errorf("advisory %s is defined in both %s and %s", a.ID, prev, from)
+	}
+
+	db.ids[a.ID] = from
+	db.byRoot[a.ProjectRoot] = append(db.byRoot[a.ProjectRoot], a)
+	return nil
+}
+
+// Len returns the number of advisories in the DB.
+func (db *DB) Len() int {
+	return len(db.ids)
+}
+
+// Advisories returns the advisories for the given project root, sorted by ID.
+func (db *DB) Advisories(pr gps.ProjectRoot) []Advisory {
+	advs := make([]Advisory, len(db.byRoot[pr]))
+	copy(advs, db.byRoot[pr])
+	sort.Sort(byID(advs))
+	return advs
+}
+
+// Check returns a Match for each project in the lock that is affected by at
+// least one advisory, in the order in which the lock lists them. A
+// gps.Solution is a Lock, and so may be checked as well.
+//
+// No matches means that no advisory in the DB affects the lock.
+func (db *DB) Check(l gps.Lock) []Match {
+	var matches []Match
+	for _, lp := range l.Projects() {
+		var advs []Advisory
+		for _, a := range db.Advisories(lp.Ident().ProjectRoot) {
+			if a.Affects(lp) {
+				advs = append(advs, a)
+			}
+		}
+
+		if len(advs) > 0 {
+			matches = append(matches, Match{
+				Project:    lp,
+				Advisories: advs,
+			})
+		}
+	}
+	return matches
+}
+
+// DenyRules converts the advisories in the DB into rules that prevent the
+// solver from selecting any affected version, for use in
+// gps.SolveParameters.Deny.
+func (db *DB) DenyRules() []gps.DenyRule {
+	var roots []string
+	for pr := range db.byRoot {
+		roots = append(roots, string(pr))
+	}
+	sort.Strings(roots)
+
+	var rules []gps.DenyRule
+	for _, r := range roots {
+		for _, a := range db.Advisories(gps.ProjectRoot(r)) {
+			for _, c := range a.Affected {
+				rules = append(rules, gps.DenyRule{
+					ProjectRoot: a.ProjectRoot,
+					Constraint:  c,
+					Reason:      a.ID,
+				})
+			}
+		}
+	}
+	return rules
+}
+
+type byID []Advisory
+
+func (s byID) Len() int {
+	return len(s)
+}
+
+func (s byID) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byID) Less(i, j int) bool {
+	return s[i].ID < s[j].ID
+}
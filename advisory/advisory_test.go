@@ -0,0 +1,142 @@
+package advisory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sdboyer/gps"
+)
+
+const libDB = `{"advisories": [
+	{
+		"id": "ADV-1",
+		"project": "example.com/lib",
+		"versions": ">=1.0.0, <1.2.0",
+		"summary": "Something \"bad\".",
+		"references": {
+			"cve": "ignored",
+			"urls": [
+				"https://example.com/ignored"
+			]
+		}
+	},
+	{
+		"id": "ADV-2",
+		"project": "example.com/lib",
+		"revisions": ["badrev", "worserev"]
+	}
+]}`
+
+const jsonDB = `{"advisories": [{
+	"id": "ADV-3",
+	"project": "example.com/other",
+	"versions": "<2.0.0",
+	"summary": "Other is broken."
+}]}`
+
+func writeDB(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "advisorydb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(p, []byte(body), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadAndCheck(t *testing.T) {
+	dir := writeDB(t, map[string]string{
+		"lib.json":         libDB,
+		"sub/other.json":   jsonDB,
+		"sub/README.md":    "not an advisory",
+		"sub/ignored.yaml": "also: not an advisory",
+	})
+	defer os.RemoveAll(dir)
+
+	db, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error loading db: %s", err)
+	}
+	if db.Len() != 3 {
+		t.Fatalf("expected 3 advisories, got %v", db.Len())
+	}
+
+	advs := db.Advisories("example.com/lib")
+	if len(advs) != 2 || advs[0].Summary != `Something "bad".` || len(advs[1].Affected) != 2 {
+		t.Errorf("advisories for lib not read as expected: %+v", advs)
+	}
+
+	lib := gps.ProjectIdentifier{ProjectRoot: "example.com/lib"}
+	other := gps.ProjectIdentifier{ProjectRoot: "example.com/other"}
+	l := gps.SimpleLock{
+		// Affected by version.
+		gps.NewLockedProject(lib, gps.NewVersion("v1.1.0").Is("goodrev"), nil),
+		// Affected by paired revision.
+		gps.NewLockedProject(lib, gps.NewVersion("v1.3.0").Is("badrev"), nil),
+		// Affected by bare revision.
+		gps.NewLockedProject(lib, gps.Revision("worserev"), nil),
+		// Unaffected.
+		gps.NewLockedProject(lib, gps.NewVersion("v1.2.0").Is("goodrev"), nil),
+		gps.NewLockedProject(lib, gps.NewBranch("master").Is("goodrev"), nil),
+		gps.NewLockedProject(other, gps.NewVersion("v2.0.0").Is("rev"), nil),
+		// Affected, on a different project.
+		gps.NewLockedProject(other, gps.NewVersion("v1.9.0").Is("rev"), nil),
+	}
+
+	matches := db.Check(l)
+	want := [][]string{{"ADV-1"}, {"ADV-2"}, {"ADV-2"}, {"ADV-3"}}
+	if len(matches) != len(want) {
+		t.Fatalf("expected %v matches, got %v: %+v", len(want), len(matches), matches)
+	}
+	for k, m := range matches {
+		var ids []string
+		for _, a := range m.Advisories {
+			ids = append(ids, a.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(want[k], ",") {
+			t.Errorf("match %v (%s): expected %v, got %v", k, m.Project.Version(), want[k], ids)
+		}
+	}
+
+	rules := db.DenyRules()
+	if len(rules) != 4 || rules[0].Reason != "ADV-1" || rules[3].ProjectRoot != "example.com/other" {
+		t.Errorf("unexpected deny rules: %v", rules)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	table := map[string]string{
+		"dupe.json": `{"advisories": [{"id": "A", "project": "p", "versions": "1.0.0"},
+			{"id": "A", "project": "p", "versions": "2.0.0"}]}`,
+		"no-affected.json":  `{"advisories": [{"id": "A", "project": "p"}]}`,
+		"bad-versions.json": `{"advisories": [{"id": "A", "project": "p", "versions": "> > 1"}]}`,
+		"bad-syntax.json":   `{"advisories": [{"id": "A",}]}`,
+		"db.toml":           "[[advisories]]\nid = \"A\"\nproject = \"p\"\nversions = \"1.0.0\"\n",
+	}
+
+	for name, body := range table {
+		dir := writeDB(t, map[string]string{name: body})
+		if _, err := Load(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		os.RemoveAll(dir)
+	}
+
+	// TOML files are rejected when found in a directory, too.
+	dir := writeDB(t, map[string]string{"ok.json": jsonDB, "sub/db.toml": ""})
+	defer os.RemoveAll(dir)
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected TOML file in directory to be rejected, got %v", err)
+	}
+}
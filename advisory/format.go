@@ -0,0 +1,54 @@
+package advisory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sdboyer/gps"
+)
+
+// rawAdvisory is the on-disk form of an Advisory.
+type rawAdvisory struct {
+	ID        string   `json:"id"`
+	Project   string   `json:"project"`
+	Versions  string   `json:"versions"`
+	Revisions []string `json:"revisions"`
+	Summary   string   `json:"summary"`
+}
+
+type rawDB struct {
+	Advisories []rawAdvisory `json:"advisories"`
+}
+
+func (raw rawAdvisory) toAdvisory() (Advisory, error) {
+	a := Advisory{
+		ID:          raw.ID,
+		ProjectRoot: gps.ProjectRoot(raw.Project),
+		Summary:     raw.Summary,
+	}
+
+	if raw.Versions != "" {
+		c, err := gps.NewSemverConstraint(raw.Versions)
+		if err != nil {
+			return Advisory{}, fmt.Errorf("advisory %s has invalid versions %q: %s", raw.ID, raw.Versions, err)
+		}
+		a.Affected = append(a.Affected, c)
+	}
+	for _, r := range raw.Revisions {
+		if r == "" {
+			return Advisory{}, fmt.Errorf("advisory %s lists an empty revision", raw.ID)
+		}
+		a.Affected = append(a.Affected, gps.Revision(r))
+	}
+
+	return a, nil
+}
+
+func readJSON(r io.Reader) ([]rawAdvisory, error) {
+	var db rawDB
+	if err := json.NewDecoder(r).Decode(&db); err != nil {
+		return nil, err
+	}
+	return db.Advisories, nil
+}
@@ -1,6 +1,7 @@
 package gps
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -33,6 +34,8 @@ type sourceBridge interface {
 	verifyRootDir(path string) error
 	vendorCodeExists(ProjectIdentifier) (bool, error)
 	breakLock()
+	prefetch(id ProjectIdentifier, c Constraint, v Version)
+	waitPrefetch()
 }
 
 // bridge is an adapter around a proper SourceManager. It provides localized
@@ -43,7 +46,7 @@ type sourceBridge interface {
 // the inputs - is achieved.
 type bridge struct {
 	// The underlying, adapted-to SourceManager
-	sm SourceManager
+	sm ContextSourceManager
 
 	// The solver which we're assisting.
 	//
@@ -69,11 +72,15 @@ type bridge struct {
 
 	// Whether to sort version lists for downgrade.
 	down bool
+
+	// Runs speculative fetches in the background. It is created on first use,
+	// and is nil if prefetching is disabled.
+	pf *prefetcher
 }
 
 // Global factory func to create a bridge. This exists solely to allow tests to
 // override it with a custom bridge and sm.
-var mkBridge = func(s *solver, sm SourceManager, down bool) sourceBridge {
+var mkBridge = func(s *solver, sm ContextSourceManager, down bool) sourceBridge {
 	return &bridge{
 		sm:     sm,
 		s:      s,
@@ -82,14 +89,37 @@ var mkBridge = func(s *solver, sm SourceManager, down bool) sourceBridge {
 	}
 }
 
+// ctx returns the context of the solve run the bridge is assisting. A bridge
+// that was not set up through Prepare has none, so it falls back to
+// context.Background().
+func (b *bridge) ctx() context.Context {
+	if b.s.ctx == nil {
+		return context.Background()
+	}
+	return b.s.ctx
+}
+
 func (b *bridge) GetManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
-	if b.s.rd.isRoot(id.ProjectRoot) {
-		return b.s.rd.rm, b.s.rd.rl, nil
+	if lr, is := b.s.rd.localRoot(id.ProjectRoot); is {
+		if id.ProjectRoot == ProjectRoot(b.s.rd.rpt.ImportRoot) {
+			return b.s.rd.rm, b.s.rd.rl, nil
+		}
+		return lr.rm, nil, nil
+	}
+
+	key := atomKey(id, v)
+	d, has := b.s.atoms[key]
+	if has && d.m != nil {
+		return d.m, d.l, nil
 	}
 
 	b.s.mtr.push("b-gmal")
-	m, l, e := b.sm.GetManifestAndLock(id, v, an)
+	m, l, e := b.sm.GetManifestAndLockContext(b.ctx(), id, v, an)
 	b.s.mtr.pop()
+	if e == nil && key != "" {
+		d.m, d.l = m, l
+		b.s.atoms[key] = d
+	}
 	return m, l, e
 }
 
@@ -99,7 +129,7 @@ func (b *bridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 	}
 
 	b.s.mtr.push("b-list-versions")
-	pvl, err := b.sm.ListVersions(id)
+	pvl, err := b.sm.ListVersionsContext(b.ctx(), id)
 	if err != nil {
 		b.s.mtr.pop()
 		return nil, err
@@ -119,14 +149,14 @@ func (b *bridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 
 func (b *bridge) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
 	b.s.mtr.push("b-rev-present-in")
-	i, e := b.sm.RevisionPresentIn(id, r)
+	i, e := b.sm.RevisionPresentInContext(b.ctx(), id, r)
 	b.s.mtr.pop()
 	return i, e
 }
 
 func (b *bridge) SourceExists(id ProjectIdentifier) (bool, error) {
 	b.s.mtr.push("b-source-exists")
-	i, e := b.sm.SourceExists(id)
+	i, e := b.sm.SourceExistsContext(b.ctx(), id)
 	b.s.mtr.pop()
 	return i, e
 }
@@ -148,13 +178,23 @@ func (b *bridge) vendorCodeExists(id ProjectIdentifier) (bool, error) {
 // The root project is handled separately, as the source manager isn't
 // responsible for that code.
 func (b *bridge) ListPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
-	if b.s.rd.isRoot(id.ProjectRoot) {
-		return b.s.rd.rpt, nil
+	if lr, is := b.s.rd.localRoot(id.ProjectRoot); is {
+		return lr.rpt, nil
+	}
+
+	key := atomKey(id, v)
+	d, has := b.s.atoms[key]
+	if has && d.ptree != nil {
+		return *d.ptree, nil
 	}
 
 	b.s.mtr.push("b-list-pkgs")
-	pt, err := b.sm.ListPackages(id, v)
+	pt, err := b.sm.ListPackagesContext(b.ctx(), id, v)
 	b.s.mtr.pop()
+	if err == nil && key != "" {
+		d.ptree = &pt
+		b.s.atoms[key] = d
+	}
 	return pt, err
 }
 
@@ -177,7 +217,7 @@ func (b *bridge) verifyRootDir(path string) error {
 
 func (b *bridge) DeduceProjectRoot(ip string) (ProjectRoot, error) {
 	b.s.mtr.push("b-deduce-proj-root")
-	pr, e := b.sm.DeduceProjectRoot(ip)
+	pr, e := b.sm.DeduceProjectRootContext(b.ctx(), ip)
 	b.s.mtr.pop()
 	return pr, e
 }
@@ -203,10 +243,10 @@ func (b *bridge) breakLock() {
 			pi, v := lp.pi, lp.Version()
 			go func() {
 				// Sync first
-				b.sm.SyncSourceFor(pi)
+				b.sm.SyncSourceForContext(b.ctx(), pi)
 				// Preload the package info for the locked version, too, as
 				// we're more likely to need that
-				b.sm.ListPackages(pi, v)
+				b.sm.ListPackagesContext(b.ctx(), pi, v)
 			}()
 		}
 	}
@@ -215,5 +255,85 @@ func (b *bridge) breakLock() {
 func (b *bridge) SyncSourceFor(id ProjectIdentifier) error {
 	// we don't track metrics here b/c this is often called in its own goroutine
 	// by the solver, and the metrics design is for wall time on a single thread
-	return b.sm.SyncSourceFor(id)
+	return b.sm.SyncSourceForContext(b.ctx(), id)
+}
+
+// prefetch speculatively fetches information about the project in the
+// background, so that it's already cached in the SourceManager when the solver
+// asks for it.
+//
+// If v is non-nil, the manifest, lock and package tree for that version are
+// fetched. If c is non-nil, the project's version list is fetched, followed by
+// the manifest, lock and package tree for the first version in the list that
+// c allows - the likely head of its version queue.
+func (b *bridge) prefetch(id ProjectIdentifier, c Constraint, v Version) {
+	if b.pf == nil {
+		if b.s.prefetch <= 0 {
+			return
+		}
+		b.pf = newPrefetcher(b.s.prefetch)
+	}
+
+	// Capture everything the background work needs, as the solver's state
+	// is not safe to read from other goroutines.
+	ctx, an, down := b.ctx(), b.s.rd.an, b.down
+	if v != nil {
+		b.prefetchAtom(ctx, id, v, an)
+	}
+	if c == nil || !b.pf.claim("versions", id, nil) {
+		return
+	}
+	if _, has := b.vlists[id]; has {
+		// Nothing more to learn.
+		return
+	}
+
+	b.pf.do(ctx, func() {
+		pvl, err := b.sm.ListVersionsContext(ctx, id)
+		if err != nil {
+			return
+		}
+
+		vl := hidePair(pvl)
+		if down {
+			SortForDowngrade(vl)
+		} else {
+			SortForUpgrade(vl)
+		}
+		for _, hv := range vl {
+			if c.Matches(hv) {
+				if b.pf.claim("atom", id, hv) {
+					b.fetchAtom(ctx, id, hv, an)
+				}
+				return
+			}
+		}
+	})
+}
+
+// prefetchAtom fetches the manifest, lock and package tree for the atom in
+// the background, unless that has already been done.
+func (b *bridge) prefetchAtom(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) {
+	if b.s.rd.isRoot(id.ProjectRoot) || !b.pf.claim("atom", id, v) {
+		return
+	}
+	if d, has := b.s.atoms[atomKey(id, v)]; has && d.m != nil && d.ptree != nil {
+		// Already on hand, perhaps from a previous solution.
+		return
+	}
+	b.pf.do(ctx, func() {
+		b.fetchAtom(ctx, id, v, an)
+	})
+}
+
+func (b *bridge) fetchAtom(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) {
+	b.sm.GetManifestAndLockContext(ctx, id, v, an)
+	b.sm.ListPackagesContext(ctx, id, v)
+}
+
+// waitPrefetch waits for any speculative fetches still running to finish.
+func (b *bridge) waitPrefetch() {
+	if b.pf != nil {
+		b.pf.wait()
+	}
 }
@@ -0,0 +1,103 @@
+package gps
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// budget bounds a solving run by time and by number of attempts, and keeps
+// enough of a record of the run's progress to explain where it got to if it
+// is stopped.
+type budget struct {
+	timeout     time.Duration
+	maxAttempts int
+
+	began time.Time
+	// Cancels the context derived for the run.
+	stop context.CancelFunc
+	// Whether the run was stopped for exceeding maxAttempts.
+	overAttempts bool
+
+	// The deepest selection reached so far, and how many entries were on the
+	// selection stack at the time.
+	deepest []LockedProject
+	depth   int
+	// The failures that caused backtracking, in the order they occurred.
+	fails []error
+}
+
+// limited indicates whether there is any budget to enforce. Progress is only
+// recorded if there is.
+func (b *budget) limited() bool {
+	return b.timeout > 0 || b.maxAttempts > 0
+}
+
+// start prepares the budget for a new run, deriving from the given context
+// one that is canceled when the budget is exceeded.
+func (b *budget) start(ctx context.Context) (context.Context, context.CancelFunc) {
+	*b = budget{
+		timeout:     b.timeout,
+		maxAttempts: b.maxAttempts,
+		began:       time.Now(),
+	}
+
+	if b.timeout > 0 {
+		ctx, b.stop = context.WithTimeout(ctx, b.timeout)
+	} else {
+		ctx, b.stop = context.WithCancel(ctx)
+	}
+	return ctx, b.stop
+}
+
+// countAttempt stops the run if the number of attempts now exceeds the
+// maximum.
+func (b *budget) countAttempt(attempts int) {
+	if b.maxAttempts > 0 && attempts > b.maxAttempts && b.stop != nil {
+		b.overAttempts = true
+		b.stop()
+	}
+}
+
+// recordSelection retains the current selection, if it is the deepest yet.
+func (b *budget) recordSelection(sel *selection) {
+	if !b.limited() || len(sel.projects) <= b.depth {
+		return
+	}
+	b.depth = len(sel.projects)
+	b.deepest = partialLock(sel.combined())
+}
+
+// recordFailure retains a failure that caused backtracking.
+func (b *budget) recordFailure(err error) {
+	if b.limited() {
+		b.fails = append(b.fails, err)
+	}
+}
+
+// exceeded reports the budget having been exceeded after the given number of
+// attempts.
+func (b *budget) exceeded(attempts int) *SolveBudgetError {
+	e := &SolveBudgetError{
+		Err:      context.DeadlineExceeded,
+		Attempts: attempts,
+		Elapsed:  time.Since(b.began),
+		Partial:  b.deepest,
+		Failures: b.fails,
+	}
+	if b.overAttempts {
+		e.Err = ErrAttemptsExceeded
+	}
+	return e
+}
+
+// partialLock converts selected projects to LockedProjects, sorted by
+// project root.
+func partialLock(projs map[atom]map[string]struct{}) []LockedProject {
+	lps := make([]LockedProject, 0, len(projs))
+	for pa, pl := range projs {
+		lps = append(lps, pa2lp(pa, pl))
+	}
+	sort.Sort(lpsorter(lps))
+	return lps
+}
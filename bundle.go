@@ -0,0 +1,486 @@
+package gps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sdboyer/gps/internal/fs"
+)
+
+// bundleFormatVersion is the version of the cache bundle format written by
+// ExportBundle(). ImportBundle() refuses bundles with any other version.
+const bundleFormatVersion = 1
+
+// bundleManifestName is the name of the archive entry describing the contents
+// of a cache bundle.
+const bundleManifestName = "gps-bundle.json"
+
+// versionSnapshotSuffix is appended to the local path of a source to name the
+// file holding the snapshot of its version list taken when it was bundled.
+const versionSnapshotSuffix = ".versions.json"
+
+type bundleManifest struct {
+	Version int            `json:"version"`
+	Sources []bundleSource `json:"sources"`
+}
+
+// bundleSource describes a single source in a cache bundle.
+type bundleSource struct {
+	// URL is the upstream URL of the source.
+	URL string `json:"url"`
+	// Type is the type of the source, e.g. "git".
+	Type string `json:"type"`
+	// Path is the slash-separated location of the source's local repository,
+	// relative to the root of the cache.
+	Path string `json:"path"`
+	// Revisions are the locked revisions the bundle was created for. All are
+	// guaranteed to be present in the bundled repository.
+	Revisions []Revision `json:"revisions"`
+	// Versions is the source's version list at the time it was bundled.
+	Versions []snapshotVersion `json:"versions"`
+}
+
+// snapshotVersion is the serialized form of a PairedVersion.
+type snapshotVersion struct {
+	Type     string   `json:"type"`
+	Name     string   `json:"name"`
+	Revision Revision `json:"revision"`
+}
+
+func toSnapshotVersions(pvl []PairedVersion) []snapshotVersion {
+	svl := make([]snapshotVersion, 0, len(pvl))
+	for _, pv := range pvl {
+		sv := snapshotVersion{
+			Name:     pv.Unpair().String(),
+			Revision: pv.Underlying(),
+		}
+
+		switch tv := pv.Unpair().(type) {
+		case branchVersion:
+			if tv.isDefault {
+				sv.Type = "default-branch"
+			} else {
+				sv.Type = "branch"
+			}
+		case semVersion, plainVersion:
+			sv.Type = "version"
+		default:
+			// Shouldn't be possible, but don't write out garbage.
+			continue
+		}
+
+		svl = append(svl, sv)
+	}
+	return svl
+}
+
+func fromSnapshotVersions(svl []snapshotVersion) ([]PairedVersion, error) {
+	pvl := make([]PairedVersion, len(svl))
+	for k, sv := range svl {
+		var v UnpairedVersion
+		switch sv.Type {
+		case "branch":
+			v = NewBranch(sv.Name)
+		case "default-branch":
+			v = newDefaultBranch(sv.Name)
+		case "version":
+			v = NewVersion(sv.Name)
+		default:
+			return nil, fmt.Errorf("unknown version type %q for %s", sv.Type, sv.Name)
+		}
+
+		pvl[k] = v.Is(sv.Revision)
+	}
+	return pvl, nil
+}
+
+func writeVersionSnapshot(path string, svl []snapshotVersion) error {
+	b, err := json.Marshal(svl)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0666)
+}
+
+func readVersionSnapshot(path string) ([]PairedVersion, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var svl []snapshotVersion
+	if err = json.Unmarshal(b, &svl); err != nil {
+		return nil, err
+	}
+	return fromSnapshotVersions(svl)
+}
+
+// trySnapshot is used by maybeSources when their upstream is unreachable. If
+// the source exists locally and was imported from a cache bundle, the version
+// list recorded in the bundle is loaded into the cache, and the states it
+// satisfies are returned.
+//
+// The upstream is assumed to exist, as it did when the bundle was created.
+func trySnapshot(lpath string, local bool, c singleSourceCache) (sourceState, bool) {
+	if !local {
+		return 0, false
+	}
+
+	vl, err := readVersionSnapshot(lpath + versionSnapshotSuffix)
+	if err != nil {
+		return 0, false
+	}
+
+	c.storeVersionMap(vl, true)
+	return sourceIsSetUp | sourceExistsUpstream | sourceExistsLocally | sourceHasLatestVersionList, true
+}
+
+// ExportBundle writes a portable archive of the sources used by the provided
+// Locks to w. The archive can be imported into another SourceMgr's cache via
+// ImportBundle(), after which that SourceMgr can solve and export the locked
+// projects without network access - for example, in an air-gapped
+// environment.
+//
+// Each source's local repository is included in full, along with a snapshot
+// of its version list. Every locked revision must be present in the local
+// cache; if any are missing, the source is updated from upstream first.
+func (sm *SourceMgr) ExportBundle(ctx context.Context, w io.Writer, locks ...Lock) error {
+	revs := make(map[ProjectIdentifier][]Revision)
+	for _, l := range locks {
+		for _, lp := range l.Projects() {
+			id := lp.Ident().normalize()
+			if lp.r != "" {
+				revs[id] = append(revs[id], lp.r)
+			} else if _, has := revs[id]; !has {
+				revs[id] = nil
+			}
+		}
+	}
+
+	ids := make([]ProjectIdentifier, 0, len(revs))
+	for id := range revs {
+		ids = append(ids, id)
+	}
+	sort.Sort(pisorter(ids))
+
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	bm := bundleManifest{Version: bundleFormatVersion}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		bs, err := srcg.writeBundle(ctx, tw, seen, revs[id])
+		if err != nil {
+			return fmt.Errorf("could not bundle %s: %s", id.errString(), err)
+		}
+		if bs != nil {
+			bm.Sources = append(bm.Sources, *bs)
+		}
+	}
+
+	b, err := json.MarshalIndent(bm, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     bundleManifestName,
+		Mode:     0666,
+		Size:     int64(len(b)),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = tw.Write(b); err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// writeBundle writes the source's local repository into the provided tar
+// stream, and returns its description for the bundle manifest. If the
+// source's repository has already been written (as recorded in seen), nil is
+// returned.
+func (sg *sourceGateway) writeBundle(ctx context.Context, tw *tar.Writer, seen map[string]bool, revs []Revision) (*bundleSource, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
+	if err != nil {
+		return nil, err
+	}
+
+	// Multiple project identifiers may map to the same source; only include
+	// it once.
+	rel, err := filepath.Rel(sg.cachedir, sg.src.localPath())
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+	if seen[rel] {
+		return nil, nil
+	}
+	seen[rel] = true
+
+	for _, r := range revs {
+		has, err := sg.src.revisionPresentIn(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if !has && sg.srcState&sourceHasLatestLocally == 0 {
+			// Maybe it's just not been fetched yet.
+			if _, err = sg.require(ctx, sourceHasLatestLocally); err != nil {
+				return nil, err
+			}
+			has, err = sg.src.revisionPresentIn(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !has {
+			return nil, fmt.Errorf("revision %s is not present in source", r)
+		}
+	}
+
+	_, err = sg.require(ctx, sourceExistsUpstream|sourceHasLatestVersionList)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := sg.lockSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctExportTree, func(ctx context.Context) error {
+		return tarDir(ctx, tw, sg.src.localPath(), rel)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bundleSource{
+		URL:       sg.src.upstreamURL(),
+		Type:      sg.src.sourceType(),
+		Path:      rel,
+		Revisions: revs,
+		Versions:  toSnapshotVersions(sg.cache.getAllVersions()),
+	}, nil
+}
+
+// tarDir writes the tree rooted at dir into tw, with all entry names prefixed
+// by prefix.
+func tarDir(ctx context.Context, tw *tar.Writer, dir, prefix string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		return err
+	})
+}
+
+// ImportBundle populates the SourceMgr's cache from an archive created by
+// ExportBundle().
+//
+// Sources that already exist in the cache are left untouched. Imported
+// sources retain their upstream URLs; when those upstreams are unreachable,
+// the version lists recorded in the bundle are used in their place.
+func (sm *SourceMgr) ImportBundle(ctx context.Context, r io.Reader) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a valid cache bundle: %s", err)
+	}
+	defer gzr.Close()
+
+	// Unpack everything into a staging dir within the cache first, so that
+	// sources can be moved into place atomically.
+	staging, err := ioutil.TempDir(sm.cachedir, "bundle-import")
+	if err != nil {
+		return err
+	}
+	defer removeAll(staging)
+
+	if err = untar(ctx, tar.NewReader(gzr), staging); err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(staging, bundleManifestName))
+	if err != nil {
+		return fmt.Errorf("not a valid cache bundle: %s", err)
+	}
+
+	var bm bundleManifest
+	if err = json.Unmarshal(b, &bm); err != nil {
+		return fmt.Errorf("not a valid cache bundle: %s", err)
+	}
+	if bm.Version != bundleFormatVersion {
+		return fmt.Errorf("unsupported cache bundle version %v", bm.Version)
+	}
+
+	for _, bs := range bm.Sources {
+		if err = sm.importBundleSource(ctx, staging, bs); err != nil {
+			return fmt.Errorf("could not import %s: %s", bs.URL, err)
+		}
+	}
+
+	return nil
+}
+
+func (sm *SourceMgr) importBundleSource(ctx context.Context, staging string, bs bundleSource) error {
+	if !isSafeBundlePath(bs.Path) || !strings.HasPrefix(bs.Path, "sources/") {
+		return fmt.Errorf("invalid source path %q", bs.Path)
+	}
+
+	// Validate the version list before touching the cache.
+	if _, err := fromSnapshotVersions(bs.Versions); err != nil {
+		return err
+	}
+
+	unlock, err := lockSourceURL(ctx, sm.cachedir, bs.URL)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dest := filepath.Join(sm.cachedir, filepath.FromSlash(bs.Path))
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+
+	if err = fs.RenameWithFallback(filepath.Join(staging, filepath.FromSlash(bs.Path)), dest); err != nil {
+		return err
+	}
+
+	return writeVersionSnapshot(dest+versionSnapshotSuffix, bs.Versions)
+}
+
+// untar extracts the contents of tr into dir. Entries that would be written
+// outside of dir are rejected.
+func untar(ctx context.Context, tr *tar.Reader, dir string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a valid cache bundle: %s", err)
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if !isSafeBundlePath(name) {
+			return fmt.Errorf("cache bundle contains invalid path %q", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0777)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeTarFile(tr, target, os.FileMode(hdr.Mode).Perm())
+		case tar.TypeSymlink:
+			if err = os.MkdirAll(filepath.Dir(target), 0777); err == nil {
+				err = os.Symlink(hdr.Linkname, target)
+			}
+		default:
+			// Nothing else should appear in a local repository.
+			err = fmt.Errorf("cache bundle contains unsupported entry %q", hdr.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeTarFile(r io.Reader, target string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm|0200)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// isSafeBundlePath checks that a slash-separated path from a cache bundle is
+// relative, and does not escape the directory it's relative to.
+func isSafeBundlePath(p string) bool {
+	if p == "" || path.IsAbs(p) || strings.Contains(p, "\\") {
+		return false
+	}
+
+	c := path.Clean(p)
+	return c == p && c != ".." && !strings.HasPrefix(c, "../")
+}
@@ -0,0 +1,248 @@
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVersionSnapshotRoundTrip(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	in := []PairedVersion{
+		newDefaultBranch("master").Is(Revision("rev1")),
+		NewBranch("v1").Is(Revision("rev2")),
+		NewVersion("v1.0.0").Is(Revision("rev3")),
+		NewVersion("plain").Is(Revision("rev4")),
+	}
+
+	lpath := filepath.Join(dir, "src")
+	if err := writeVersionSnapshot(lpath+versionSnapshotSuffix, toSnapshotVersions(in)); err != nil {
+		t.Fatalf("unexpected error writing snapshot: %s", err)
+	}
+
+	out, err := readVersionSnapshot(lpath + versionSnapshotSuffix)
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %s", err)
+	}
+
+	if len(out) != len(in) {
+		t.Fatalf("expected %v versions, got %v", len(in), len(out))
+	}
+	for k, v := range in {
+		if v != out[k] {
+			t.Errorf("version %v did not round-trip: wanted %s, got %s", k, v, out[k])
+		}
+	}
+
+	c := newMemoryCache()
+	if _, ok := trySnapshot(lpath, false, c); ok {
+		t.Error("snapshot should not be used if the source does not exist locally")
+	}
+	state, ok := trySnapshot(lpath, true, c)
+	if !ok {
+		t.Fatal("expected snapshot to be used")
+	}
+	if state&sourceHasLatestVersionList == 0 {
+		t.Error("snapshot should satisfy sourceHasLatestVersionList")
+	}
+	if len(c.getAllVersions()) != len(in) {
+		t.Error("snapshot versions should have been stored in the cache")
+	}
+}
+
+func TestTarDirRoundTrip(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	src := filepath.Join(dir, "src")
+	files := map[string]string{
+		"a":            "foo",
+		"sub/b":        "bar",
+		"sub/deep/c.x": "baz",
+	}
+	for name, content := range files {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tarDir(context.Background(), tw, src, "sources/foo"); err != nil {
+		t.Fatalf("unexpected error on tarDir: %s", err)
+	}
+	tw.Close()
+
+	dest := filepath.Join(dir, "dest")
+	if err := untar(context.Background(), tar.NewReader(&buf), dest); err != nil {
+		t.Fatalf("unexpected error on untar: %s", err)
+	}
+
+	for name, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dest, "sources", "foo", filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("missing %s after untar: %s", name, err)
+		} else if string(b) != content {
+			t.Errorf("wrong content for %s: wanted %q, got %q", name, content, string(b))
+		}
+	}
+}
+
+func TestUntarRejectsEscapes(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	for _, name := range []string{"../evil", "/abs/evil", "sources/../../evil", `sources\..\evil`} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0666, Size: 1, Typeflag: tar.TypeReg})
+		tw.Write([]byte("x"))
+		tw.Close()
+
+		if err := untar(context.Background(), tar.NewReader(&buf), dir); err == nil {
+			t.Errorf("expected untar to reject entry %q", name)
+		}
+	}
+}
+
+func TestBundleExportImport(t *testing.T) {
+	// This test is a bit slow, skip it on -short
+	if testing.Short() {
+		t.Skip("Skipping bundle export/import test in short mode")
+	}
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	id := mkPI("github.com/sdboyer/gpkt").normalize()
+	l := SimpleLock{
+		NewLockedProject(id, NewVersion("v1.0.0").Is(Revision("bf85021c0405edbc4f3648b0603818d641674f72")), nil),
+	}
+
+	var buf bytes.Buffer
+	if err := sm.ExportBundle(context.Background(), &buf, l); err != nil {
+		t.Fatalf("unexpected error on bundle export: %s", err)
+	}
+
+	sm2, clean2 := mkNaiveSM(t)
+	defer clean2()
+
+	if err := sm2.ImportBundle(context.Background(), &buf); err != nil {
+		t.Fatalf("unexpected error on bundle import: %s", err)
+	}
+
+	lpath := filepath.Join(sm2.cachedir, "sources", "https---github.com-sdboyer-gpkt")
+	if _, err := os.Stat(filepath.Join(lpath, ".git")); err != nil {
+		t.Errorf("imported source should exist in the cache: %s", err)
+	}
+	if _, err := readVersionSnapshot(lpath + versionSnapshotSuffix); err != nil {
+		t.Errorf("imported source should have a version snapshot: %s", err)
+	}
+}
+
+func TestBundleImportOffline(t *testing.T) {
+	requiresBins(t, "git")
+
+	tempDir, err := ioutil.TempDir("", "bundletest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := removeAll(tempDir); err != nil {
+			t.Errorf("removeAll failed: %s", err)
+		}
+	}()
+
+	git := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = mergeEnvLists([]string{
+			"GIT_AUTHOR_NAME=gps", "GIT_AUTHOR_EMAIL=gps@example.com",
+			"GIT_COMMITTER_NAME=gps", "GIT_COMMITTER_EMAIL=gps@example.com",
+		}, os.Environ())
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	// The upstream doesn't exist, so the source can only be set up from the
+	// snapshot recorded in the bundle.
+	remote := "https://github.com/sdboyer/gpkt-nonexistent"
+	repo := filepath.Join(tempDir, "repo")
+	if err = os.MkdirAll(repo, 0777); err != nil {
+		t.Fatal(err)
+	}
+	git(repo, "init")
+	git(repo, "symbolic-ref", "HEAD", "refs/heads/master")
+	git(repo, "remote", "add", "origin", remote)
+	if err = ioutil.WriteFile(filepath.Join(repo, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git(repo, "add", "a.go")
+	git(repo, "commit", "-m", "initial")
+	git(repo, "tag", "v1.0.0")
+	rev := Revision(git(repo, "rev-parse", "HEAD"))
+
+	bs := bundleSource{
+		URL:       remote,
+		Type:      "git",
+		Path:      "sources/" + sanitizer.Replace(remote),
+		Revisions: []Revision{rev},
+		Versions: toSnapshotVersions([]PairedVersion{
+			newDefaultBranch("master").Is(rev),
+			NewVersion("v1.0.0").Is(rev),
+		}),
+	}
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	if err = tarDir(context.Background(), tw, repo, bs.Path); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(bundleManifest{Version: bundleFormatVersion, Sources: []bundleSource{bs}})
+	tw.WriteHeader(&tar.Header{Name: bundleManifestName, Mode: 0666, Size: int64(len(b)), Typeflag: tar.TypeReg})
+	tw.Write(b)
+	tw.Close()
+	gzw.Close()
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	if err = sm.ImportBundle(context.Background(), &buf); err != nil {
+		t.Fatalf("unexpected error on bundle import: %s", err)
+	}
+
+	id := mkPI("github.com/sdboyer/gpkt-nonexistent")
+	vl, err := sm.ListVersions(id)
+	if err != nil {
+		t.Fatalf("unexpected error listing versions of imported source: %s", err)
+	}
+	if len(vl) != 2 {
+		t.Errorf("expected 2 versions from bundle snapshot, got %v", len(vl))
+	}
+
+	to := filepath.Join(tempDir, "export")
+	if err = sm.ExportProject(id, NewVersion("v1.0.0"), to); err != nil {
+		t.Fatalf("unexpected error exporting imported source: %s", err)
+	}
+	if _, err = os.Stat(filepath.Join(to, "a.go")); err != nil {
+		t.Errorf("exported tree is missing a.go: %s", err)
+	}
+}
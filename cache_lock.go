@@ -0,0 +1,249 @@
+package gps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errLockContended is returned by the platform-specific locking functions when
+// a lock could not be acquired without blocking.
+var errLockContended = errors.New("lock is held by another process")
+
+// errLockUnsupported is returned by the platform-specific locking functions
+// when the OS or filesystem does not support advisory locking. In that case,
+// cacheLocks fall back to PID files.
+var errLockUnsupported = errors.New("advisory file locking is not supported")
+
+// A cacheLock is a cross-process lock on some portion of a SourceMgr's cache
+// directory.
+//
+// SourceMgrs already serialize work on each source within a single process;
+// cacheLocks extend those guarantees to multiple processes sharing a cachedir.
+// Shared locks may be held by any number of processes at once, whereas an
+// exclusive lock excludes all other holders, shared or exclusive.
+//
+// Where the OS provides them, advisory locks (flock(2), LockFileEx) are used,
+// so locks are released by the kernel if the holding process dies. On systems
+// or filesystems where advisory locking is unavailable, a cacheLock falls back
+// to creating a PID file alongside the lock file. A PID file whose owning
+// process is no longer running is considered stale, and is removed.
+type cacheLock struct {
+	path string
+	mu   sync.Mutex // guards all fields below
+	f    *os.File
+	held bool
+	excl bool
+	pidf bool // true if the lock is held via a PID file
+}
+
+func newCacheLock(path string) *cacheLock {
+	return &cacheLock{path: path}
+}
+
+// CacheLockedError indicates that some portion of a SourceMgr's cache could
+// not be locked because another process holds a conflicting lock.
+type CacheLockedError struct {
+	Path string
+	// PID of the process holding the lock, or 0 if it could not be determined.
+	PID int
+	Err error
+}
+
+func (e CacheLockedError) Error() string {
+	var holder string
+	if e.PID != 0 {
+		holder = fmt.Sprintf(" (held by process %d)", e.PID)
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf("could not lock %s%s: %s", e.Path, holder, e.Err)
+	}
+	return fmt.Sprintf("could not lock %s%s", e.Path, holder)
+}
+
+// tryLock attempts to acquire the lock without blocking. If the lock is held
+// by another process, a CacheLockedError is returned.
+func (cl *cacheLock) tryLock(exclusive bool) error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.held {
+		return fmt.Errorf("lock on %s is already held by this process", cl.path)
+	}
+
+	if cl.f == nil {
+		f, err := os.OpenFile(cl.path, os.O_RDWR|os.O_CREATE, 0666)
+		if err != nil {
+			return err
+		}
+		cl.f = f
+	}
+
+	switch err := lockFile(cl.f, exclusive); err {
+	case nil:
+		// If the fallback was used at some point, a PID file may still be
+		// present, from us or from another process. Respect it, unless stale.
+		if pid, has := cl.pidFileOwner(); has {
+			unlockFile(cl.f)
+			return CacheLockedError{Path: cl.path, PID: pid}
+		}
+	case errLockContended:
+		return CacheLockedError{Path: cl.path, PID: cl.recordedPID()}
+	case errLockUnsupported:
+		if err := cl.lockPIDFile(exclusive); err != nil {
+			return err
+		}
+		cl.pidf = true
+	default:
+		return err
+	}
+
+	if exclusive && !cl.pidf {
+		// Record our PID so that contending processes can report the holder.
+		// This is informational only, so errors are ignored.
+		if cl.f.Truncate(0) == nil {
+			cl.f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		}
+	}
+
+	cl.held, cl.excl = true, exclusive
+	return nil
+}
+
+// lock acquires the lock, waiting for as long as is necessary - or until the
+// provided context is canceled.
+func (cl *cacheLock) lock(ctx context.Context, exclusive bool) error {
+	wait := 10 * time.Millisecond
+	for {
+		err := cl.tryLock(exclusive)
+		if _, ok := err.(CacheLockedError); !ok {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			lerr := err.(CacheLockedError)
+			lerr.Err = ctx.Err()
+			return lerr
+		case <-time.After(wait):
+		}
+
+		// Back off, up to a reasonable maximum, so that long holds (e.g. an
+		// initial clone of a large repository) don't keep us spinning.
+		if wait < time.Second {
+			wait *= 2
+		}
+	}
+}
+
+// unlock releases the lock. It is safe to call on an unheld lock.
+func (cl *cacheLock) unlock() error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if !cl.held {
+		return nil
+	}
+	cl.held = false
+
+	if cl.pidf {
+		cl.pidf = false
+		if cl.excl {
+			return os.Remove(cl.pidPath())
+		}
+		return nil
+	}
+
+	if cl.excl {
+		cl.f.Truncate(0)
+	}
+	return unlockFile(cl.f)
+}
+
+// close releases the lock, if held, and closes the underlying file handle.
+func (cl *cacheLock) close() error {
+	err := cl.unlock()
+
+	cl.mu.Lock()
+	if cl.f != nil {
+		cl.f.Close()
+		cl.f = nil
+	}
+	cl.mu.Unlock()
+	return err
+}
+
+func (cl *cacheLock) pidPath() string {
+	return cl.path + ".pid"
+}
+
+// lockPIDFile implements the fallback locking strategy. Exclusive locks are
+// represented by a PID file created with O_EXCL; shared locks only check that
+// no live exclusive holder exists.
+func (cl *cacheLock) lockPIDFile(exclusive bool) error {
+	for {
+		if pid, has := cl.pidFileOwner(); has {
+			return CacheLockedError{Path: cl.path, PID: pid}
+		}
+
+		if !exclusive {
+			return nil
+		}
+
+		f, err := os.OpenFile(cl.pidPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			// Lost a race with another process; go around again.
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+		f.Close()
+		if err != nil {
+			os.Remove(cl.pidPath())
+		}
+		return err
+	}
+}
+
+// pidFileOwner reports the PID recorded in the fallback PID file, if one
+// exists and its owner is still running. Stale PID files are removed.
+func (cl *cacheLock) pidFileOwner() (int, bool) {
+	b, err := ioutil.ReadFile(cl.pidPath())
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || (pid != os.Getpid() && !processExists(pid)) {
+		// Either garbage, or a crashed process left it behind. Either way,
+		// it's stale and safe to remove.
+		os.Remove(cl.pidPath())
+		return 0, false
+	}
+
+	return pid, true
+}
+
+// recordedPID returns the PID written to the lock file by the current
+// exclusive holder, or 0 if there is none.
+func (cl *cacheLock) recordedPID() int {
+	b, err := ioutil.ReadFile(cl.path)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,138 @@
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func mkLockDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cachelock")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+
+	return dir, func() {
+		if err := removeAll(dir); err != nil {
+			t.Errorf("removeAll failed: %s", err)
+		}
+	}
+}
+
+func TestCacheLockSharedAndExclusive(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	lpath := filepath.Join(dir, "test.lock")
+	s1, s2, x := newCacheLock(lpath), newCacheLock(lpath), newCacheLock(lpath)
+	defer s1.close()
+	defer s2.close()
+	defer x.close()
+
+	if err := s1.tryLock(false); err != nil {
+		t.Fatalf("Unexpected error on first shared lock: %s", err)
+	}
+	if err := s2.tryLock(false); err != nil {
+		t.Fatalf("Shared locks should not contend, but got: %s", err)
+	}
+
+	if err := x.tryLock(true); err == nil {
+		t.Fatal("Exclusive lock should have failed while shared locks are held")
+	} else if _, ok := err.(CacheLockedError); !ok {
+		t.Fatalf("Expected CacheLockedError, got %T: %s", err, err)
+	}
+
+	s1.unlock()
+	s2.unlock()
+
+	if err := x.tryLock(true); err != nil {
+		t.Fatalf("Exclusive lock should have succeeded after shared locks released: %s", err)
+	}
+
+	err := s1.tryLock(false)
+	if err == nil {
+		t.Fatal("Shared lock should have failed while exclusive lock is held")
+	}
+
+	lerr, ok := err.(CacheLockedError)
+	if !ok {
+		t.Fatalf("Expected CacheLockedError, got %T: %s", err, err)
+	}
+	if lerr.PID != os.Getpid() {
+		t.Errorf("Expected exclusive holder to be reported as PID %v, got %v", os.Getpid(), lerr.PID)
+	}
+}
+
+func TestCacheLockWaitsForRelease(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	lpath := filepath.Join(dir, "test.lock")
+	x1, x2 := newCacheLock(lpath), newCacheLock(lpath)
+	defer x1.close()
+	defer x2.close()
+
+	if err := x1.tryLock(true); err != nil {
+		t.Fatalf("Unexpected error on exclusive lock: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	err := x2.lock(ctx, true)
+	cancel()
+	if err == nil {
+		t.Fatal("Expected lock attempt to time out while lock was held")
+	} else if lerr, ok := err.(CacheLockedError); !ok || lerr.Err != context.DeadlineExceeded {
+		t.Fatalf("Expected CacheLockedError wrapping deadline error, got %T: %s", err, err)
+	}
+
+	go func() {
+		<-time.After(20 * time.Millisecond)
+		x1.unlock()
+	}()
+
+	if err = x2.lock(context.Background(), true); err != nil {
+		t.Fatalf("Expected lock to be acquired after release, got: %s", err)
+	}
+}
+
+func TestCacheLockStalePIDFile(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	cl := newCacheLock(filepath.Join(dir, "test.lock"))
+	defer cl.close()
+
+	// Find a PID that's very unlikely to be running.
+	pid := 1 << 22
+	for processExists(pid) {
+		pid++
+	}
+
+	err := ioutil.WriteFile(cl.pidPath(), []byte(strconv.Itoa(pid)+"\n"), 0666)
+	if err != nil {
+		t.Fatalf("Failed to write PID file: %s", err)
+	}
+
+	if err = cl.lockPIDFile(true); err != nil {
+		t.Fatalf("Stale PID file should have been ignored, but got: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(cl.pidPath())
+	if err != nil {
+		t.Fatalf("Failed to read PID file: %s", err)
+	}
+	if string(b) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("Expected PID file to be rewritten with our PID, got %q", string(b))
+	}
+
+	// A live PID file must block other lockers.
+	other := newCacheLock(cl.path)
+	defer other.close()
+	if err = other.tryLock(false); err == nil {
+		t.Error("Expected lock to fail while a live PID file is present")
+	}
+}
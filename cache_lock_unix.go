@@ -0,0 +1,42 @@
+//+build darwin dragonfly freebsd linux netbsd openbsd
+
+package gps
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	for {
+		err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+		// ENOTSUP and EOPNOTSUPP are the same value on some platforms, so this
+		// can't be a switch.
+		if err == nil {
+			return nil
+		} else if err == syscall.EINTR {
+			continue
+		} else if err == syscall.EWOULDBLOCK {
+			return errLockContended
+		} else if err == syscall.ENOLCK || err == syscall.ENOTSUP || err == syscall.EOPNOTSUPP {
+			return errLockUnsupported
+		}
+		return &os.PathError{Op: "flock", Path: f.Name(), Err: err}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// processExists reports whether a process with the given PID is running.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means the process exists, but belongs to someone else.
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,72 @@
+package gps
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+
+	errorLockViolation syscall.Errno = 33
+	errorNotSupported  syscall.Errno = 50
+	errorIOPending     syscall.Errno = 997
+
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+func lockFile(f *os.File, exclusive bool) error {
+	var flags uint32 = lockfileFailImmediately
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+
+	ol := new(syscall.Overlapped)
+	r1, _, err := procLockFileEx.Call(f.Fd(), uintptr(flags), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r1 != 0 {
+		return nil
+	}
+
+	switch err {
+	case errorLockViolation, errorIOPending:
+		return errLockContended
+	case errorNotSupported:
+		return errLockUnsupported
+	default:
+		return &os.PathError{Op: "LockFileEx", Path: f.Name(), Err: err}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	ol := new(syscall.Overlapped)
+	r1, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r1 == 0 {
+		return &os.PathError{Op: "UnlockFileEx", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// processExists reports whether a process with the given PID is running.
+func processExists(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Access denied means the process exists, but belongs to someone else.
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err = syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
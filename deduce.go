@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	radix "github.com/armon/go-radix"
 )
@@ -540,6 +541,8 @@ type deductionCoordinator struct {
 	mut      sync.RWMutex
 	rootxt   *radix.Tree
 	deducext *deducerTrie
+	store    *deductionStore // persists go-get metadata results; may be nil
+	schemes  *schemePolicy   // governs go-get metadata retrieval; may be nil
 }
 
 func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
@@ -607,6 +610,8 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 	hmd := &httpMetadataDeducer{
 		basePath: path,
 		suprvsr:  dc.suprvsr,
+		store:    dc.store,
+		schemes:  dc.schemes,
 		// The vanity deducer will call this func with a completed
 		// pathDeduction if it succeeds in finding one. We process it
 		// back through the action channel to ensure serialized
@@ -628,6 +633,38 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 	return hmd.deduce(ctx, path)
 }
 
+// invalidate removes persisted deductions for the given roots - or all of
+// them, if none are given - along with any in-memory deductions for those
+// roots.
+func (dc *deductionCoordinator) invalidate(roots ...string) error {
+	if len(roots) == 0 {
+		recs, err := dc.store.list()
+		if err != nil {
+			return err
+		}
+		for _, rec := range recs {
+			roots = append(roots, rec.Root)
+		}
+	}
+
+	dc.mut.Lock()
+	defer dc.mut.Unlock()
+	for _, root := range roots {
+		if err := dc.store.remove(root); err != nil {
+			return err
+		}
+
+		// Leave in-flight deductions be; they'll finish with fresh data.
+		if data, has := dc.rootxt.Get(root); has {
+			if _, ok := data.(maybeSource); ok {
+				dc.rootxt.Delete(root)
+			}
+		}
+	}
+
+	return nil
+}
+
 // pathDeduction represents the results of a successful import path deduction -
 // a root path, plus a maybeSource that can be used to attempt to connect to
 // the source.
@@ -685,6 +722,8 @@ type httpMetadataDeducer struct {
 	basePath   string
 	returnFunc func(pathDeduction)
 	suprvsr    *supervisor
+	store      *deductionStore // may be nil, in which case nothing is persisted
+	schemes    *schemePolicy   // may be nil, in which case all schemes are allowed
 }
 
 func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDeduction, error) {
@@ -698,15 +737,42 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 
 		pd := pathDeduction{}
 
-		// Make the HTTP call to attempt to retrieve go-get metadata
+		// If a previous fetch of the metadata was persisted, and is recent
+		// enough, use it. Otherwise, hang on to it as a fallback in case the
+		// metadata host can't be reached.
+		var rec DeductionRecord
+		var hasRec bool
+		if hmd.store != nil {
+			rec, hasRec = hmd.store.find(path)
+		}
+
 		var root, vcs, reporoot string
-		err = hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
-			root, vcs, reporoot, err = parseMetadata(ctx, path, u.Scheme)
-			return err
-		})
-		if err != nil {
-			hmd.deduceErr = fmt.Errorf("unable to deduce repository and source type for: %q", opath)
-			return
+		if hasRec && hmd.store.fresh(rec) {
+			root, vcs, reporoot = rec.Root, rec.VCS, rec.RepoRoot
+		} else {
+			// Make the HTTP call to attempt to retrieve go-get metadata
+			err = hmd.suprvsr.doHost(ctx, u.Host, path, ctHTTPMetadata, func(ctx context.Context) error {
+				root, vcs, reporoot, err = parseMetadata(ctx, path, u.Scheme, hmd.schemes.rule(u.Host))
+				return err
+			})
+
+			if err == nil {
+				if hmd.store != nil {
+					// Failing to persist only costs a refetch later, so
+					// errors are ignored.
+					hmd.store.put(DeductionRecord{
+						Root:     root,
+						VCS:      vcs,
+						RepoRoot: reporoot,
+						Fetched:  time.Now(),
+					})
+				}
+			} else if hasRec {
+				root, vcs, reporoot = rec.Root, rec.VCS, rec.RepoRoot
+			} else {
+				hmd.deduceErr = fmt.Errorf("unable to deduce repository and source type for: %q", opath)
+				return
+			}
 		}
 		pd.root = root
 
@@ -797,7 +863,7 @@ func normalizeURI(p string) (u *url.URL, newpath string, err error) {
 }
 
 // fetchMetadata fetches the remote metadata for path.
-func fetchMetadata(ctx context.Context, path, scheme string) (rc io.ReadCloser, err error) {
+func fetchMetadata(ctx context.Context, path, scheme string, rule SchemeRule) (rc io.ReadCloser, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("unable to determine remote metadata protocol: %s", err)
@@ -805,13 +871,20 @@ func fetchMetadata(ctx context.Context, path, scheme string) (rc io.ReadCloser,
 	}()
 
 	if scheme == "http" {
+		if !rule.allows("http") {
+			return nil, fmt.Errorf("http is not permitted by the scheme policy for %s", path)
+		}
 		rc, err = doFetchMetadata(ctx, "http", path)
 		return
 	}
 
-	rc, err = doFetchMetadata(ctx, "https", path)
-	if err == nil {
-		return
+	if rule.allows("https") {
+		rc, err = doFetchMetadata(ctx, "https", path)
+		if err == nil || !rule.allows("http") {
+			return
+		}
+	} else if !rule.allows("http") {
+		return nil, fmt.Errorf("neither https nor http is permitted by the scheme policy for %s", path)
 	}
 
 	rc, err = doFetchMetadata(ctx, "http", path)
@@ -842,9 +915,9 @@ func doFetchMetadata(ctx context.Context, scheme, path string) (io.ReadCloser, e
 //
 // scheme is optional. If it's http, only http will be attempted for fetching.
 // Any other scheme (including none) will first try https, then fall back to
-// http.
-func parseMetadata(ctx context.Context, path, scheme string) (string, string, string, error) {
-	rc, err := fetchMetadata(ctx, path, scheme)
+// http. Schemes not allowed by the provided rule are never attempted.
+func parseMetadata(ctx context.Context, path, scheme string, rule SchemeRule) (string, string, string, error) {
+	rc, err := fetchMetadata(ctx, path, scheme, rule)
 	if err != nil {
 		return "", "", "", err
 	}
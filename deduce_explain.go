@@ -0,0 +1,226 @@
+package gps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// DeductionMethod indicates how the root and source of an import path were
+// deduced.
+type DeductionMethod uint8
+
+// DeductionMethods for the three ways in which import paths are deduced.
+const (
+	// DeducedByStaticRule indicates the import path matched one of gps'
+	// built-in rules for well-known hosts, like github.com or gopkg.in.
+	DeducedByStaticRule DeductionMethod = iota
+	// DeducedByVCSExtension indicates the import path contained a VCS
+	// extension, like ".git", marking the repository root.
+	DeducedByVCSExtension
+	// DeducedByHTTPMetadata indicates the import path was deduced by fetching
+	// go-get metadata from the path's host.
+	DeducedByHTTPMetadata
+)
+
+func (m DeductionMethod) String() string {
+	switch m {
+	case DeducedByStaticRule:
+		return "static rule"
+	case DeducedByVCSExtension:
+		return "vcs extension"
+	case DeducedByHTTPMetadata:
+		return "go-get metadata"
+	}
+	return fmt.Sprintf("DeductionMethod(%d)", uint8(m))
+}
+
+// SourceCandidate describes one of the sources that an import path's root
+// could be retrieved from.
+type SourceCandidate struct {
+	// URL is the candidate source's URL.
+	URL string
+	// Type is the type of source, e.g. "git".
+	Type string
+	// Scheme is the URL scheme used to access the source.
+	Scheme string
+	// Tried indicates whether setting up the source was attempted. Candidates
+	// are attempted in order, until one succeeds.
+	Tried bool
+	// Err is the reason the candidate could not be set up, if it was tried
+	// and failed.
+	Err error
+	// Selected indicates that this is the candidate gps would use.
+	Selected bool
+	// Denied indicates that the candidate is excluded by the SourceMgr's
+	// SchemePolicy, and so is never tried.
+	Denied bool
+}
+
+// DeductionExplanation describes how gps deduces the root and source for an
+// import path, and the outcome of trying each of the possible sources.
+type DeductionExplanation struct {
+	// ImportPath is the import path that was explained.
+	ImportPath string
+	// Method is how the root was deduced.
+	Method DeductionMethod
+	// Rule is the static rule prefix matched, if Method is
+	// DeducedByStaticRule.
+	Rule string
+	// Record is the persisted go-get metadata used, if Method is
+	// DeducedByHTTPMetadata and a record exists.
+	Record *DeductionRecord
+	// Root is the deduced project root. It is empty if deduction failed.
+	Root ProjectRoot
+	// Err is the error encountered during deduction, if any. If non-nil,
+	// there are no Candidates.
+	Err error
+	// Candidates are the possible sources for the root, in the order in
+	// which they are attempted, followed by any denied by the SchemePolicy.
+	Candidates []SourceCandidate
+}
+
+func (de DeductionExplanation) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s: deduced by %s", de.ImportPath, de.Method)
+	if de.Rule != "" {
+		fmt.Fprintf(&buf, " (%s)", de.Rule)
+	}
+	if de.Record != nil {
+		fmt.Fprintf(&buf, " (persisted, fetched %s)", de.Record.Fetched.Format("2006-01-02 15:04:05 MST"))
+	}
+	buf.WriteString("\n")
+
+	if de.Err != nil {
+		fmt.Fprintf(&buf, "\tfailed: %s\n", de.Err)
+		return buf.String()
+	}
+
+	fmt.Fprintf(&buf, "\troot: %s\n", de.Root)
+	for _, c := range de.Candidates {
+		var status string
+		switch {
+		case c.Selected:
+			status = "selected"
+		case c.Denied:
+			status = "denied by scheme policy"
+		case !c.Tried:
+			status = "not tried"
+		default:
+			status = fmt.Sprintf("failed: %s", c.Err)
+		}
+		fmt.Fprintf(&buf, "\t%s %s: %s\n", c.Type, c.URL, status)
+	}
+
+	return buf.String()
+}
+
+// ExplainDeduction reports in detail how the provided import path is
+// deduced: which deducer matched, the root it chose, and the candidate
+// sources for that root. Each candidate is tried in the same order as when
+// setting up a source, stopping at the first that succeeds.
+//
+// Failures to deduce or set up sources are reported within the returned
+// explanation, rather than as an error.
+func (sm *SourceMgr) ExplainDeduction(ctx context.Context, ip string) (DeductionExplanation, error) {
+	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
+		return DeductionExplanation{}, smIsReleased{}
+	}
+
+	de, mb := sm.deduceCoord.explain(ctx, ip)
+	if de.Err != nil {
+		return de, nil
+	}
+
+	allowed, denied := sm.srcCoord.schemes.filter(flattenMaybeSources(nil, mb))
+
+	var found bool
+	for _, m := range allowed {
+		c := describeMaybeSource(m)
+		if !found {
+			// Use a throwaway cache, so that explaining has no effect on
+			// subsequent operations.
+			_, _, err := m.try(ctx, sm.cachedir, newMemoryCache(), sm.suprvsr)
+			c.Tried = true
+			c.Err = err
+			c.Selected = err == nil
+			found = c.Selected
+		}
+		de.Candidates = append(de.Candidates, c)
+	}
+
+	for _, m := range denied {
+		c := describeMaybeSource(m)
+		c.Denied = true
+		de.Candidates = append(de.Candidates, c)
+	}
+
+	return de, nil
+}
+
+// explain deduces the root for the given import path, classifying how it was
+// deduced. The deduced maybeSource is also returned.
+func (dc *deductionCoordinator) explain(ctx context.Context, path string) (DeductionExplanation, maybeSource) {
+	de := DeductionExplanation{ImportPath: path}
+
+	// Classify using the same order of checks as deduceKnownPaths().
+	_, npath, err := normalizeURI(path)
+	if err != nil {
+		de.Err = err
+		return de, nil
+	}
+
+	if prefix, _, has := dc.deducext.LongestPrefix(npath); has {
+		de.Method, de.Rule = DeducedByStaticRule, prefix
+	} else if _, err := (vcsExtensionDeducer{regexp: vcsExtensionRegex}).deduceRoot(npath); err == nil {
+		de.Method = DeducedByVCSExtension
+	} else {
+		de.Method = DeducedByHTTPMetadata
+	}
+
+	pd, err := dc.deduceRootPath(ctx, path)
+	if err != nil {
+		de.Err = err
+		return de, nil
+	}
+	de.Root = ProjectRoot(pd.root)
+
+	if de.Method == DeducedByHTTPMetadata && dc.store != nil {
+		if rec, has := dc.store.find(npath); has {
+			de.Record = &rec
+		}
+	}
+
+	return de, pd.mb
+}
+
+func flattenMaybeSources(to []maybeSource, mb maybeSource) []maybeSource {
+	switch tmb := mb.(type) {
+	case nil:
+	case maybeSources:
+		for _, m := range tmb {
+			to = flattenMaybeSources(to, m)
+		}
+	default:
+		to = append(to, mb)
+	}
+	return to
+}
+
+func describeMaybeSource(mb maybeSource) SourceCandidate {
+	c := SourceCandidate{URL: mb.getURL()}
+	if u := maybeSourceURL(mb); u != nil {
+		c.URL, c.Scheme = u.String(), u.Scheme
+	}
+
+	switch mb.(type) {
+	case maybeGitSource, maybeGopkginSource:
+		c.Type = "git"
+	case maybeBzrSource:
+		c.Type = "bzr"
+	case maybeHgSource:
+		c.Type = "hg"
+	}
+	return c
+}
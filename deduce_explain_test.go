@@ -0,0 +1,126 @@
+package gps
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeductionCoordinatorExplain(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	superv := newSupervisor(context.Background())
+	dc := newDeductionCoordinator(superv)
+	dc.store = newDeductionStore(dir, time.Hour)
+	err := dc.store.put(DeductionRecord{
+		Root:     "vanity.invalid/foo",
+		VCS:      "git",
+		RepoRoot: "https://github.com/sdboyer/gpkt",
+		Fetched:  time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		in     string
+		method DeductionMethod
+		rule   string
+		root   ProjectRoot
+		urls   []string
+	}{
+		{
+			in:     "github.com/sdboyer/gps/pkgtree",
+			method: DeducedByStaticRule,
+			rule:   "github.com/",
+			root:   "github.com/sdboyer/gps",
+			urls: []string{
+				"https://github.com/sdboyer/gps",
+				"ssh://git@github.com/sdboyer/gps",
+				"git://github.com/sdboyer/gps",
+				"http://github.com/sdboyer/gps",
+			},
+		},
+		{
+			in:     "example.com/foo.git/bar",
+			method: DeducedByVCSExtension,
+			root:   "example.com/foo.git",
+		},
+		{
+			in:     "vanity.invalid/foo/bar",
+			method: DeducedByHTTPMetadata,
+			root:   "vanity.invalid/foo",
+			urls:   []string{"https://github.com/sdboyer/gpkt"},
+		},
+	}
+
+	for _, fix := range table {
+		de, mb := dc.explain(context.Background(), fix.in)
+		if de.Err != nil {
+			t.Errorf("%s: unexpected error: %s", fix.in, de.Err)
+			continue
+		}
+		if de.Method != fix.method {
+			t.Errorf("%s: expected method %s, got %s", fix.in, fix.method, de.Method)
+		}
+		if de.Rule != fix.rule {
+			t.Errorf("%s: expected rule %q, got %q", fix.in, fix.rule, de.Rule)
+		}
+		if de.Root != fix.root {
+			t.Errorf("%s: expected root %q, got %q", fix.in, fix.root, de.Root)
+		}
+		if (de.Record != nil) != (fix.method == DeducedByHTTPMetadata) {
+			t.Errorf("%s: persisted record should be reported only for go-get metadata deductions", fix.in)
+		}
+
+		if fix.urls == nil {
+			continue
+		}
+		mbs := flattenMaybeSources(nil, mb)
+		if len(mbs) != len(fix.urls) {
+			t.Errorf("%s: expected %v candidates, got %v", fix.in, len(fix.urls), len(mbs))
+			continue
+		}
+		for k, m := range mbs {
+			if c := describeMaybeSource(m); c.URL != fix.urls[k] || c.Type != "git" {
+				t.Errorf("%s: expected candidate %v to be git %s, got %s %s", fix.in, k, fix.urls[k], c.Type, c.URL)
+			}
+		}
+	}
+
+	de, _ := dc.explain(context.Background(), "vanity.invalid/nope")
+	if de.Err == nil {
+		t.Error("expected deduction error to be reported in explanation")
+	}
+	if de.Method != DeducedByHTTPMetadata {
+		t.Errorf("expected failed deduction to still report its method, got %s", de.Method)
+	}
+}
+
+func TestExplainDeduction(t *testing.T) {
+	// This test is a bit slow, skip it on -short
+	if testing.Short() {
+		t.Skip("Skipping deduction explanation test in short mode")
+	}
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	de, err := sm.ExplainDeduction(context.Background(), "github.com/sdboyer/gpkt")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(de.Candidates) != 4 {
+		t.Fatalf("expected 4 candidates, got %v", len(de.Candidates))
+	}
+
+	if !de.Candidates[0].Tried || !de.Candidates[0].Selected {
+		t.Errorf("expected first candidate to be tried and selected, got %+v", de.Candidates[0])
+	}
+	for _, c := range de.Candidates[1:] {
+		if c.Tried {
+			t.Errorf("no candidates after the selected one should be tried, but %s was", c.URL)
+		}
+	}
+}
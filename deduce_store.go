@@ -0,0 +1,230 @@
+package gps
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDeductionTTL is the length of time for which a persisted go-get
+// metadata deduction is used without being refreshed.
+const DefaultDeductionTTL = 24 * time.Hour
+
+// DeductionRecord is the persisted result of deducing a project root from
+// go-get metadata (i.e., by fetching "?go-get=1" from a vanity import host).
+type DeductionRecord struct {
+	// Root is the import path of the project root that was deduced.
+	Root string `json:"root"`
+	// VCS is the type of source indicated by the metadata, e.g. "git".
+	VCS string `json:"vcs"`
+	// RepoRoot is the URL of the source indicated by the metadata.
+	RepoRoot string `json:"repo"`
+	// Fetched is when the metadata was retrieved.
+	Fetched time.Time `json:"fetched"`
+}
+
+// deductionStore persists DeductionRecords in a directory within the cache,
+// one file per root.
+//
+// Each record is written atomically, and records are never modified in place,
+// so no locking is necessary for multiple processes to share a store.
+//
+// Records are also looked up in the corresponding directories of any
+// read-only lower cache layers, though those in the writable directory take
+// precedence.
+type deductionStore struct {
+	dir   string
+	lower []string
+	mu    sync.RWMutex // guards ttl
+	ttl   time.Duration
+}
+
+func newDeductionStore(dir string, ttl time.Duration, lower ...string) *deductionStore {
+	return &deductionStore{
+		dir:   dir,
+		lower: lower,
+		ttl:   ttl,
+	}
+}
+
+func (ds *deductionStore) setTTL(ttl time.Duration) {
+	ds.mu.Lock()
+	ds.ttl = ttl
+	ds.mu.Unlock()
+}
+
+// fresh indicates whether the record is young enough to be used without
+// refreshing it.
+func (ds *deductionStore) fresh(rec DeductionRecord) bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.ttl > 0 && time.Since(rec.Fetched) < ds.ttl
+}
+
+func (ds *deductionStore) recordPath(root string) string {
+	return recordPathIn(ds.dir, root)
+}
+
+// recordPathIn returns the path of the file holding the record for the root in
+// the given directory. Files are named by a hash of the root, so that no two
+// roots can share one.
+func recordPathIn(dir, root string) string {
+	return filepath.Join(dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(root))))
+}
+
+// find looks up the record with the longest root that is a prefix of, or
+// equal to, the given (normalized) import path.
+func (ds *deductionStore) find(path string) (DeductionRecord, bool) {
+	for p := path; p != "." && p != ""; {
+		if rec, err := ds.get(p); err == nil && strings.HasPrefix(path, rec.Root) && isPathPrefixOrEqual(rec.Root, path) {
+			return rec, true
+		}
+
+		k := strings.LastIndex(p, "/")
+		if k == -1 {
+			break
+		}
+		p = p[:k]
+	}
+
+	return DeductionRecord{}, false
+}
+
+// get reads the record for the root, from the writable directory if it's
+// there, or else from the first lower layer that has it.
+func (ds *deductionStore) get(root string) (DeductionRecord, error) {
+	rec, err := readRecord(ds.recordPath(root))
+	for _, dir := range ds.lower {
+		if !os.IsNotExist(err) {
+			break
+		}
+		rec, err = readRecord(recordPathIn(dir, root))
+	}
+	if err == nil && rec.Root != root {
+		return DeductionRecord{}, fmt.Errorf("record for %s holds %s instead", root, rec.Root)
+	}
+	return rec, err
+}
+
+func readRecord(path string) (DeductionRecord, error) {
+	var rec DeductionRecord
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return rec, err
+	}
+
+	err = json.Unmarshal(b, &rec)
+	return rec, err
+}
+
+func (ds *deductionStore) put(rec DeductionRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(ds.dir, 0777); err != nil {
+		return err
+	}
+
+	// Write to a temp file, then rename into place, so readers never see a
+	// partially-written record.
+	f, err := ioutil.TempFile(ds.dir, "record")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), ds.recordPath(rec.Root))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// list returns all records in the store, including those only in lower
+// layers, sorted by root.
+func (ds *deductionStore) list() ([]DeductionRecord, error) {
+	seen := make(map[string]bool)
+	var recs []DeductionRecord
+	for _, dir := range append([]string{ds.dir}, ds.lower...) {
+		fis, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, fi := range fis {
+			if fi.IsDir() || filepath.Ext(fi.Name()) != ".json" {
+				continue
+			}
+
+			rec, err := readRecord(filepath.Join(dir, fi.Name()))
+			if os.IsNotExist(err) {
+				// Removed since the directory was read.
+				continue
+			} else if _, ok := err.(*os.PathError); ok {
+				return nil, err
+			} else if err != nil {
+				// Corrupt records are simply ignored; a fresh fetch will
+				// overwrite them.
+				continue
+			}
+			if filepath.Base(recordPathIn(dir, rec.Root)) != fi.Name() {
+				// Not where the record would be looked for - perhaps left over
+				// from an older naming scheme - so it can never be used.
+				continue
+			}
+			if !seen[rec.Root] {
+				seen[rec.Root] = true
+				recs = append(recs, rec)
+			}
+		}
+	}
+
+	sort.Sort(drsorter(recs))
+	return recs, nil
+}
+
+// remove deletes the record for the root from the writable directory. Lower
+// layers can't be written to, so if one of them has a record for the root, a
+// copy of it with a zero Fetched time is written in its place instead. That
+// copy is never fresh, so the metadata is fetched anew when next needed, but
+// it remains available as a fallback.
+func (ds *deductionStore) remove(root string) error {
+	err := os.Remove(ds.recordPath(root))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, dir := range ds.lower {
+		rec, err := readRecord(recordPathIn(dir, root))
+		if err != nil {
+			continue
+		}
+		if rec.Fetched.IsZero() {
+			return nil
+		}
+		rec.Fetched = time.Time{}
+		return ds.put(rec)
+	}
+	return nil
+}
+
+type drsorter []DeductionRecord
+
+func (s drsorter) Len() int           { return len(s) }
+func (s drsorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s drsorter) Less(i, j int) bool { return s[i].Root < s[j].Root }
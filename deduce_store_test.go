@@ -0,0 +1,267 @@
+package gps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeductionStore(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	ds := newDeductionStore(filepath.Join(dir, "deductions"), time.Hour)
+
+	recs, err := ds.list()
+	if err != nil {
+		t.Fatalf("listing a nonexistent store should not error, got: %s", err)
+	}
+	if len(recs) != 0 {
+		t.Fatalf("expected no records in new store, got %v", len(recs))
+	}
+
+	now := time.Now()
+	for _, rec := range []DeductionRecord{
+		{Root: "vanity.example/foo", VCS: "git", RepoRoot: "https://github.com/example/foo", Fetched: now},
+		{Root: "vanity.example/bar", VCS: "hg", RepoRoot: "https://hg.example/bar", Fetched: now.Add(-2 * time.Hour)},
+	} {
+		if err = ds.put(rec); err != nil {
+			t.Fatalf("unexpected error on put: %s", err)
+		}
+	}
+
+	rec, has := ds.find("vanity.example/foo/sub/pkg")
+	if !has {
+		t.Fatal("expected to find record for subpackage of stored root")
+	}
+	if rec.Root != "vanity.example/foo" || rec.VCS != "git" {
+		t.Errorf("found wrong record: %+v", rec)
+	}
+	if !ds.fresh(rec) {
+		t.Error("record fetched just now should be fresh")
+	}
+
+	if _, has = ds.find("vanity.example/foobar"); has {
+		t.Error("should not match a root that is only a string prefix")
+	}
+
+	rec, _ = ds.find("vanity.example/bar")
+	if ds.fresh(rec) {
+		t.Error("record older than TTL should not be fresh")
+	}
+
+	recs, err = ds.list()
+	if err != nil {
+		t.Fatalf("unexpected error on list: %s", err)
+	}
+	if len(recs) != 2 || recs[0].Root != "vanity.example/bar" || recs[1].Root != "vanity.example/foo" {
+		t.Errorf("expected two records sorted by root, got %+v", recs)
+	}
+
+	if err = ds.remove("vanity.example/foo"); err != nil {
+		t.Fatalf("unexpected error on remove: %s", err)
+	}
+	if _, has = ds.find("vanity.example/foo"); has {
+		t.Error("record should be gone after removal")
+	}
+	if err = ds.remove("vanity.example/foo"); err != nil {
+		t.Errorf("removing a nonexistent record should not error, got: %s", err)
+	}
+}
+
+func TestDeductionStoreDistinctRoots(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	ds := newDeductionStore(filepath.Join(dir, "deductions"), time.Hour)
+
+	// Roots that differ only in characters a filename can't hold must not
+	// share a record.
+	now := time.Now()
+	for _, rec := range []DeductionRecord{
+		{Root: "a.example/b-c", VCS: "git", RepoRoot: "https://git.example/b-c", Fetched: now},
+		{Root: "a.example/b/c", VCS: "hg", RepoRoot: "https://hg.example/b/c", Fetched: now},
+	} {
+		if err := ds.put(rec); err != nil {
+			t.Fatalf("unexpected error on put: %s", err)
+		}
+	}
+
+	for root, repo := range map[string]string{
+		"a.example/b-c": "https://git.example/b-c",
+		"a.example/b/c": "https://hg.example/b/c",
+	} {
+		rec, has := ds.find(root + "/pkg")
+		if !has || rec.Root != root || rec.RepoRoot != repo {
+			t.Errorf("expected record for %s, got %+v", root, rec)
+		}
+	}
+
+	// A record stored under the wrong name is never used for another path.
+	if err := os.Rename(recordPathIn(ds.dir, "a.example/b-c"), recordPathIn(ds.dir, "other.example/x")); err != nil {
+		t.Fatal(err)
+	}
+	if rec, has := ds.find("other.example/x/pkg"); has {
+		t.Errorf("should not use a record whose root is not a prefix of the path, got %+v", rec)
+	}
+	recs, err := ds.list()
+	if err != nil {
+		t.Fatalf("unexpected error on list: %s", err)
+	}
+	if len(recs) != 1 || recs[0].Root != "a.example/b/c" {
+		t.Errorf("expected misplaced record to be left out of list, got %+v", recs)
+	}
+}
+
+func TestDeductionStoreLowerLayers(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	now := time.Now()
+	lowerDir := filepath.Join(dir, "lower", "deductions")
+	lower := newDeductionStore(lowerDir, time.Hour)
+	for _, rec := range []DeductionRecord{
+		{Root: "vanity.example/foo", VCS: "git", RepoRoot: "https://github.com/example/foo", Fetched: now},
+		{Root: "vanity.example/bar", VCS: "hg", RepoRoot: "https://hg.example/bar", Fetched: now},
+	} {
+		if err := lower.put(rec); err != nil {
+			t.Fatalf("unexpected error on put: %s", err)
+		}
+	}
+
+	ds := newDeductionStore(filepath.Join(dir, "top", "deductions"), time.Hour, lowerDir)
+	rec, has := ds.find("vanity.example/foo/sub")
+	if !has || rec.RepoRoot != "https://github.com/example/foo" {
+		t.Fatalf("expected to find record from lower layer, got %+v", rec)
+	}
+
+	// Records in the writable directory take precedence.
+	if err := ds.put(DeductionRecord{Root: "vanity.example/foo", VCS: "git", RepoRoot: "https://git.example/foo", Fetched: now}); err != nil {
+		t.Fatalf("unexpected error on put: %s", err)
+	}
+	if rec, _ = ds.find("vanity.example/foo"); rec.RepoRoot != "https://git.example/foo" {
+		t.Errorf("expected record from writable directory to take precedence, got %+v", rec)
+	}
+
+	recs, err := ds.list()
+	if err != nil {
+		t.Fatalf("unexpected error on list: %s", err)
+	}
+	if len(recs) != 2 || recs[0].Root != "vanity.example/bar" || recs[1].RepoRoot != "https://git.example/foo" {
+		t.Errorf("expected two records, with the writable one shadowing the lower, got %+v", recs)
+	}
+
+	// Removing a record that's in a lower layer leaves a stale copy.
+	if err = ds.remove("vanity.example/bar"); err != nil {
+		t.Fatalf("unexpected error on remove: %s", err)
+	}
+	rec, has = ds.find("vanity.example/bar")
+	if !has || rec.RepoRoot != "https://hg.example/bar" {
+		t.Fatalf("expected record from lower layer to remain as a fallback, got %+v", rec)
+	}
+	if ds.fresh(rec) {
+		t.Error("record from lower layer should be stale after removal")
+	}
+	if rec, _ = lower.find("vanity.example/bar"); !ds.fresh(rec) {
+		t.Error("lower layer should not be modified by removal")
+	}
+}
+
+func TestHTTPDeductionUsesStore(t *testing.T) {
+	dir, clean := mkLockDir(t)
+	defer clean()
+
+	// .invalid is reserved, so fetching metadata will always fail.
+	const root = "vanity.invalid/foo"
+	ds := newDeductionStore(dir, time.Hour)
+	err := ds.put(DeductionRecord{
+		Root:     root,
+		VCS:      "git",
+		RepoRoot: "https://github.com/sdboyer/gpkt",
+		Fetched:  time.Now().Add(-2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deduce := func() (pathDeduction, *supervisor, error) {
+		superv := newSupervisor(context.Background())
+		dc := newDeductionCoordinator(superv)
+		dc.store = ds
+		pd, err := dc.deduceRootPath(context.Background(), root+"/bar")
+		return pd, superv, err
+	}
+
+	// The record is stale, so a fetch is attempted; it fails, so the stale
+	// record is used.
+	pd, superv, err := deduce()
+	if err != nil {
+		t.Fatalf("expected stale record to be used as a fallback, got: %s", err)
+	}
+	if pd.root != root {
+		t.Errorf("expected root %q, got %q", root, pd.root)
+	}
+	if mb, ok := pd.mb.(maybeGitSource); !ok || mb.url.String() != "https://github.com/sdboyer/gpkt" {
+		t.Errorf("unexpected maybeSource from stale record: %#v", pd.mb)
+	}
+	if _, has := superv.ran[ctHTTPMetadata]; !has {
+		t.Error("expected a metadata fetch to be attempted for a stale record")
+	}
+
+	// With a long enough TTL, the record is fresh and no fetch is attempted.
+	ds.setTTL(3 * time.Hour)
+	pd, superv, err = deduce()
+	if err != nil {
+		t.Fatalf("unexpected error with fresh record: %s", err)
+	}
+	if pd.root != root {
+		t.Errorf("expected root %q, got %q", root, pd.root)
+	}
+	if _, has := superv.ran[ctHTTPMetadata]; has {
+		t.Error("no metadata fetch should be made when a fresh record exists")
+	}
+
+	// Without any record, deduction fails.
+	if err = ds.remove(root); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = deduce(); err == nil {
+		t.Error("expected deduction to fail with no record and an unreachable host")
+	}
+}
+
+func TestSourceMgrDeductionAPI(t *testing.T) {
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	for _, root := range []string{"vanity.example/foo", "vanity.example/bar"} {
+		err := sm.deduceCoord.store.put(DeductionRecord{Root: root, VCS: "git", RepoRoot: "https://" + root, Fetched: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recs, err := sm.ListDeductions()
+	if err != nil {
+		t.Fatalf("unexpected error listing deductions: %s", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 deductions, got %v", len(recs))
+	}
+
+	if err = sm.InvalidateDeductions("vanity.example/foo"); err != nil {
+		t.Fatalf("unexpected error invalidating deduction: %s", err)
+	}
+	if recs, _ = sm.ListDeductions(); len(recs) != 1 || recs[0].Root != "vanity.example/bar" {
+		t.Errorf("expected only vanity.example/bar to remain, got %+v", recs)
+	}
+
+	if err = sm.InvalidateDeductions(); err != nil {
+		t.Fatalf("unexpected error invalidating all deductions: %s", err)
+	}
+	if recs, _ = sm.ListDeductions(); len(recs) != 0 {
+		t.Errorf("expected no deductions to remain, got %+v", recs)
+	}
+}
@@ -0,0 +1,90 @@
+package gps
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A DenyRule marks versions of a project as unusable, as for a release with a
+// known vulnerability.
+//
+// Unlike an override, a DenyRule does not pin the project; the solver skips
+// over the denied versions and is free to select any other.
+type DenyRule struct {
+	// ProjectRoot is the project to which the rule applies.
+	ProjectRoot ProjectRoot
+
+	// Constraint admits the versions that are denied. A Revision denies that
+	// revision, regardless of the version under which it is reached.
+	Constraint Constraint
+
+	// Reason optionally explains why the versions are denied, e.g. with the
+	// identifier of an advisory. It is included in failure messages.
+	Reason string
+}
+
+func (r DenyRule) String() string {
+	if r.Reason == "" {
+		return fmt.Sprintf("%s %s", r.ProjectRoot, r.Constraint)
+	}
+	return fmt.Sprintf("%s %s (%s)", r.ProjectRoot, r.Constraint, r.Reason)
+}
+
+// denyRules indexes DenyRules by ProjectRoot.
+type denyRules map[ProjectRoot][]DenyRule
+
+func prepDenyRules(rules []DenyRule) (denyRules, error) {
+	dr := make(denyRules)
+	for _, r := range rules {
+		if r.ProjectRoot == "" {
+			return nil, badOptsFailure("deny rules must specify a ProjectRoot")
+		}
+		if r.Constraint == nil {
+			return nil, badOptsFailure(fmt.Sprintf("deny rule for %s lacks a Constraint", r.ProjectRoot))
+		}
+		dr[r.ProjectRoot] = append(dr[r.ProjectRoot], r)
+	}
+	return dr, nil
+}
+
+// asSortedSlice returns the rules sorted by ProjectRoot, and then by
+// constraint, for stable hashing.
+func (dr denyRules) asSortedSlice() []DenyRule {
+	var rules []DenyRule
+	for _, rl := range dr {
+		rules = append(rules, rl...)
+	}
+	sort.Sort(sortedDenyRules(rules))
+	return rules
+}
+
+type sortedDenyRules []DenyRule
+
+func (s sortedDenyRules) Len() int {
+	return len(s)
+}
+
+func (s sortedDenyRules) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortedDenyRules) Less(i, j int) bool {
+	if s[i].ProjectRoot != s[j].ProjectRoot {
+		return s[i].ProjectRoot < s[j].ProjectRoot
+	}
+	return s[i].Constraint.typedString() < s[j].Constraint.typedString()
+}
+
+// checkAtomNotDenied ensures that an atom's version is not denied by any of
+// the DenyRules provided in the SolveParameters.
+func (s *solver) checkAtomNotDenied(pa atom) error {
+	for _, r := range s.rd.deny[pa.id.ProjectRoot] {
+		if s.vUnify.matches(pa.id, r.Constraint, pa.v) {
+			return &versionDeniedFailure{
+				goal: pa,
+				rule: r,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package gps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/armon/go-radix"
+)
+
+// A DependencyReason explains why a project, or one of its packages, is part
+// of a Solution.
+type DependencyReason struct {
+	// ProjectRoot is the project that was asked about, or that contains the
+	// package that was asked about.
+	ProjectRoot ProjectRoot
+
+	// Version is the version of the project in the solution.
+	Version Version
+
+	// ImportChains lists, for each package in the root project or required
+	// by the root manifest from which the package or project is reached, the
+	// shortest chain of imports through which it is reached. Each chain lists
+	// import paths in import order. Chains for a project end at the first of
+	// its packages reached.
+	ImportChains [][]string
+
+	// Constraints are the constraints that the root and selected projects
+	// placed on the project, and that the selected version satisfies.
+	Constraints []DependencyConstraint
+}
+
+// A DependencyConstraint is a constraint placed on a project by one of the
+// projects that depend on it.
+type DependencyConstraint struct {
+	// Depender is the project declaring the dependency.
+	Depender ProjectRoot
+	// DependerVersion is the version of Depender in the solution. It is nil if
+	// Depender is the root project.
+	DependerVersion Version
+	// Constraint is the constraint in effect for the dependency.
+	Constraint Constraint
+	// Overridden indicates that Constraint comes from an override in the
+	// root manifest, in place of what Depender declared.
+	Overridden bool
+	// Packages are the packages within the project that Depender imports.
+	Packages []string
+}
+
+func (dc DependencyConstraint) String() string {
+	depender := a2vs(atom{id: ProjectIdentifier{ProjectRoot: dc.Depender}, v: dc.DependerVersion})
+	if dc.DependerVersion == nil {
+		depender = "(root)"
+	}
+
+	if dc.Overridden {
+		return fmt.Sprintf("%s (overridden) from %s", dc.Constraint, depender)
+	}
+	return fmt.Sprintf("%s from %s", dc.Constraint, depender)
+}
+
+// depGraph records the package imports and project dependencies among the
+// projects selected in a solution, so that the solution can explain itself.
+type depGraph struct {
+	// The packages in the root project.
+	root map[string]bool
+	// The packages the root manifest requires, which act as additional roots.
+	req map[string]bool
+	// The project containing each package, for packages outside the root.
+	owner map[string]ProjectRoot
+	// For each package, the packages that import it.
+	importers map[string][]string
+	// The selected version of each project.
+	versions map[ProjectRoot]Version
+	// The constraints on each project.
+	constraints map[ProjectRoot][]DependencyConstraint
+	// A radix tree of the selected project roots, for mapping packages.
+	roots *radix.Tree
+}
+
+// buildDepGraph records the current selection, which must be complete, as a
+// depGraph.
+func (s *solver) buildDepGraph() (*depGraph, error) {
+	g := &depGraph{
+		root:        make(map[string]bool),
+		req:         make(map[string]bool),
+		owner:       make(map[string]ProjectRoot),
+		importers:   make(map[string][]string),
+		versions:    make(map[ProjectRoot]Version),
+		constraints: make(map[ProjectRoot][]DependencyConstraint),
+		roots:       radix.New(),
+	}
+
+	imports := make(map[string][]string)
+	for _, lr := range s.rd.ws {
+		for pkg, poe := range lr.rpt.Packages {
+			if poe.Err != nil || lr.ig[pkg] {
+				continue
+			}
+			g.root[pkg] = true
+			imports[pkg] = poe.P.ImportsFor(s.rd.targets, true)
+		}
+	}
+
+	for _, sel := range s.sel.projects[1:] {
+		pr := sel.a.a.id.ProjectRoot
+		if _, has := g.versions[pr]; !has {
+			g.versions[pr] = sel.a.a.v
+			g.roots.Insert(string(pr), pr)
+		}
+
+		ptree, err := s.b.ListPackages(sel.a.a.id, sel.a.a.v)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range sel.a.pl {
+			g.owner[pkg] = pr
+			if poe, has := ptree.Packages[pkg]; has && poe.Err == nil {
+				imports[pkg] = poe.P.ImportsFor(s.rd.targets, false)
+			}
+		}
+	}
+
+	for pkg := range s.rd.req {
+		if _, has := g.owner[pkg]; has {
+			g.req[pkg] = true
+		}
+	}
+
+	for pkg, imps := range imports {
+		for _, imp := range imps {
+			if imp == pkg || s.rd.ig[imp] || s.rd.isStdLib(imp) {
+				continue
+			}
+			if _, has := g.owner[imp]; !has && !g.root[imp] {
+				// Not part of the solution.
+				continue
+			}
+			g.importers[imp] = append(g.importers[imp], pkg)
+		}
+	}
+	for pkg := range g.importers {
+		sort.Strings(g.importers[pkg])
+	}
+
+	for pr := range g.versions {
+		for _, dep := range s.sel.deps[pr] {
+			dc := DependencyConstraint{
+				Depender:   dep.depender.id.ProjectRoot,
+				Constraint: dep.dep.Constraint,
+				Overridden: dep.dep.overrConstraint,
+				Packages:   append([]string{}, dep.dep.pl...),
+			}
+			if !s.rd.isRoot(dc.Depender) {
+				dc.DependerVersion = dep.depender.v
+			}
+			sort.Strings(dc.Packages)
+			g.constraints[pr] = append(g.constraints[pr], dc)
+		}
+	}
+
+	return g, nil
+}
+
+// why explains why the project or package at the given path is in the graph.
+func (g *depGraph) why(path string) (DependencyReason, error) {
+	if g == nil {
+		return DependencyReason{}, fmt.Errorf("no dependency information is available")
+	}
+
+	_, ipr, has := g.roots.LongestPrefix(path)
+	if !has || !isPathPrefixOrEqual(string(ipr.(ProjectRoot)), path) {
+		return DependencyReason{}, fmt.Errorf("%s is not in the solution", path)
+	}
+	pr := ipr.(ProjectRoot)
+
+	dr := DependencyReason{
+		ProjectRoot: pr,
+		Version:     g.versions[pr],
+		Constraints: g.constraints[pr],
+	}
+
+	var targets []string
+	if path == string(pr) {
+		// Chains to a project end at whichever of its packages is reached
+		// first, so start from those imported from outside the project, and
+		// any that are required outright.
+		for pkg, owner := range g.owner {
+			if owner != pr {
+				continue
+			}
+			if g.req[pkg] {
+				targets = append(targets, pkg)
+				continue
+			}
+			for _, imp := range g.importers[pkg] {
+				if g.owner[imp] != pr {
+					targets = append(targets, pkg)
+					break
+				}
+			}
+		}
+		sort.Strings(targets)
+	} else {
+		if _, has := g.owner[path]; !has {
+			return DependencyReason{}, fmt.Errorf("package %s is not in the solution", path)
+		}
+		targets = []string{path}
+	}
+
+	dr.ImportChains = g.chainsTo(targets, pr, path == string(pr))
+	sort.Sort(importChains(dr.ImportChains))
+
+	return dr, nil
+}
+
+// chainsTo finds the shortest chain of imports to any of the targets from
+// each root or required package that reaches them. It searches breadth-first
+// back along importers, visiting each package at most once, so that graphs
+// with many paths between two packages stay cheap to explain.
+//
+// If wholeProject is set, the targets are all in project pr, and chains may
+// not pass through its other packages, as they would end there instead.
+func (g *depGraph) chainsTo(targets []string, pr ProjectRoot, wholeProject bool) [][]string {
+	// For each package reached, the package it imports on its shortest path
+	// to a target.
+	next := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		seen[t] = true
+	}
+
+	chainFrom := func(pkg string) []string {
+		chain := []string{pkg}
+		for p, has := next[pkg]; has; p, has = next[p] {
+			chain = append(chain, p)
+		}
+		return chain
+	}
+
+	var out [][]string
+	queue := append([]string(nil), targets...)
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if g.req[cur] {
+			out = append(out, chainFrom(cur))
+		}
+
+		for _, imp := range g.importers[cur] {
+			if seen[imp] {
+				continue
+			}
+			if wholeProject && !g.root[imp] && g.owner[imp] == pr {
+				continue
+			}
+
+			seen[imp] = true
+			next[imp] = cur
+			if g.root[imp] {
+				out = append(out, chainFrom(imp))
+				continue
+			}
+			queue = append(queue, imp)
+		}
+	}
+
+	return out
+}
+
+type importChains [][]string
+
+func (s importChains) Len() int {
+	return len(s)
+}
+
+func (s importChains) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s importChains) Less(i, j int) bool {
+	return strings.Join(s[i], " ") < strings.Join(s[j], " ")
+}
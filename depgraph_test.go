@@ -0,0 +1,177 @@
+package gps
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/armon/go-radix"
+)
+
+func TestSolutionWhy(t *testing.T) {
+	fix := bimodalFixture{
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0"),
+				pkg("root", "root/foo", "a"),
+				pkg("root/foo", "b"),
+			),
+			dsp(mkDepspec("a 1.0.0", "c ^1.0.0"),
+				pkg("a", "c/x"),
+			),
+			dsp(mkDepspec("b 1.0.0"),
+				pkg("b", "c"),
+			),
+			dsp(mkDepspec("c 1.0.0"),
+				pkg("c", "c/x"),
+				pkg("c/x"),
+			),
+			dsp(mkDepspec("c 1.1.0"),
+				pkg("c", "c/x"),
+				pkg("c/x"),
+			),
+		},
+	}
+
+	for _, mvs := range []bool{false, true} {
+		params := SolveParameters{
+			RootDir:         string(fix.ds[0].n),
+			RootPackageTree: fix.rootTree(),
+			Manifest:        fix.rootmanifest(),
+			ProjectAnalyzer: naiveAnalyzer{},
+			MVS:             mvs,
+			Retain:          true,
+		}
+
+		s, err := Prepare(params, newbmSM(fix))
+		if err != nil {
+			t.Fatalf("unexpected error while preparing solver: %s", err)
+		}
+		soln, err := s.Solve()
+		if err != nil {
+			t.Fatalf("(mvs: %v) unexpected error while solving: %s", mvs, err)
+		}
+		rs, ok := soln.(RetainedSolution)
+		if !ok {
+			t.Fatalf("(mvs: %v) expected a RetainedSolution, got %T", mvs, soln)
+		}
+
+		dr, err := rs.Why("c")
+		if err != nil {
+			t.Fatalf("(mvs: %v) unexpected error explaining c: %s", mvs, err)
+		}
+
+		wantv := NewVersion("1.1.0")
+		if mvs {
+			wantv = NewVersion("1.0.0")
+		}
+		if dr.ProjectRoot != "c" || dr.Version != wantv {
+			t.Errorf("(mvs: %v) expected c at %s, got %s at %s", mvs, wantv, dr.ProjectRoot, dr.Version)
+		}
+
+		want := [][]string{
+			{"root", "a", "c/x"},
+			{"root/foo", "b", "c"},
+		}
+		if !reflect.DeepEqual(dr.ImportChains, want) {
+			t.Errorf("(mvs: %v) unexpected import chains for c:\n\t(GOT): %v\n\t(WNT): %v", mvs, dr.ImportChains, want)
+		}
+
+		if len(dr.Constraints) != 2 {
+			t.Fatalf("(mvs: %v) expected constraints from a and b, got %v", mvs, dr.Constraints)
+		}
+		for _, dc := range dr.Constraints {
+			switch dc.Depender {
+			case "a":
+				if dc.Constraint.String() != "^1.0.0" || dc.DependerVersion != NewVersion("1.0.0") || !reflect.DeepEqual(dc.Packages, []string{"c/x"}) {
+					t.Errorf("(mvs: %v) unexpected constraint from a: %s %v", mvs, dc, dc.Packages)
+				}
+			case "b":
+				if dc.Constraint != Any() || !reflect.DeepEqual(dc.Packages, []string{"c"}) {
+					t.Errorf("(mvs: %v) unexpected constraint from b: %s %v", mvs, dc, dc.Packages)
+				}
+			default:
+				t.Errorf("(mvs: %v) unexpected depender %s", mvs, dc.Depender)
+			}
+		}
+
+		// A package may be reached through others in its own project.
+		dr, err = rs.Why("c/x")
+		if err != nil {
+			t.Fatalf("(mvs: %v) unexpected error explaining c/x: %s", mvs, err)
+		}
+		want = [][]string{
+			{"root", "a", "c/x"},
+			{"root/foo", "b", "c", "c/x"},
+		}
+		if !reflect.DeepEqual(dr.ImportChains, want) {
+			t.Errorf("(mvs: %v) unexpected import chains for c/x:\n\t(GOT): %v\n\t(WNT): %v", mvs, dr.ImportChains, want)
+		}
+
+		dr, err = rs.Why("a")
+		if err != nil {
+			t.Fatalf("(mvs: %v) unexpected error explaining a: %s", mvs, err)
+		}
+		if len(dr.Constraints) != 1 || dr.Constraints[0].DependerVersion != nil || dr.Constraints[0].String() != "* from (root)" {
+			t.Errorf("(mvs: %v) expected a lone constraint from the root on a, got %v", mvs, dr.Constraints)
+		}
+
+		for _, path := range []string{"d", "c/y", "root/foo"} {
+			if _, err = rs.Why(path); err == nil {
+				t.Errorf("(mvs: %v) expected an error explaining %s, which is not in the solution", mvs, path)
+			}
+		}
+	}
+}
+
+func TestDepGraphChainsDiamonds(t *testing.T) {
+	// A stack of diamonds: each package in a layer is imported by both
+	// packages in the layer above, so there are 2^layers distinct chains from
+	// the root to the bottom.
+	const layers = 40
+	g := &depGraph{
+		root:      map[string]bool{"root": true},
+		req:       make(map[string]bool),
+		owner:     make(map[string]ProjectRoot),
+		importers: make(map[string][]string),
+		versions:  map[ProjectRoot]Version{"p": NewVersion("1.0.0")},
+		roots:     radix.New(),
+	}
+	g.roots.Insert("p", ProjectRoot("p"))
+
+	above := []string{"root"}
+	for i := 0; i < layers; i++ {
+		layer := []string{fmt.Sprintf("p/%v/a", i), fmt.Sprintf("p/%v/b", i)}
+		for _, pkg := range layer {
+			g.owner[pkg] = "p"
+			g.importers[pkg] = above
+		}
+		above = layer
+	}
+
+	dr, err := g.why(above[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"root"}
+	for i := 0; i < layers; i++ {
+		want = append(want, fmt.Sprintf("p/%v/a", i))
+	}
+	if !reflect.DeepEqual(dr.ImportChains, [][]string{want}) {
+		t.Errorf("expected the single shortest chain from root, got %v", dr.ImportChains)
+	}
+
+	// A required package in the middle is an origin of its own.
+	g.req["p/20/b"] = true
+	dr, _ = g.why(above[0])
+	if len(dr.ImportChains) != 2 || len(dr.ImportChains[0]) != 20 || dr.ImportChains[0][0] != "p/20/b" {
+		t.Errorf("expected a chain from the required package as well, got %v", dr.ImportChains)
+	}
+
+	dr, err = g.why("p")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(dr.ImportChains, [][]string{{"p/20/b"}, {"root", "p/0/a"}}) {
+		t.Errorf("expected one chain into the project from root, and the required package, got %v", dr.ImportChains)
+	}
+}
@@ -0,0 +1,261 @@
+package gps
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An Incompatibility is a set of selections - projects at particular versions
+// - that cannot all be part of a solution, along with the reason why.
+//
+// Incompatibilities are either learned directly from a single failure (in
+// which case Cause is set), or derived from the incompatibilities that ruled
+// out every version of some other project (in which case Project and Rejected
+// are set). Following the derivations from the incompatibility returned in an
+// UnsolvableError leads back to the direct conflicts that made solving fail.
+type Incompatibility struct {
+	// Selections are the selections that are incompatible with one another.
+	// If empty, no solution is possible at all.
+	Selections []IncompatibleSelection
+
+	// Cause is the failure from which the incompatibility was learned, if it
+	// was learned directly.
+	Cause error
+
+	// Project is, for derived incompatibilities, the project for which no
+	// version could be selected alongside Selections.
+	Project ProjectIdentifier
+	// RequiredBy are the selections whose requirements made it necessary to
+	// select some version of Project. A nil Version indicates the root
+	// project.
+	RequiredBy []IncompatibleSelection
+	// Rejected lists each version of Project that was tried, and the
+	// incompatibility that ruled it out.
+	Rejected []RejectedVersion
+}
+
+// IncompatibleSelection is a project at a particular version.
+type IncompatibleSelection struct {
+	Project ProjectIdentifier
+	Version Version
+}
+
+func (is IncompatibleSelection) String() string {
+	return a2vs(atom{id: is.Project, v: is.Version})
+}
+
+// RejectedVersion is a version that was ruled out by an incompatibility.
+type RejectedVersion struct {
+	Version Version
+	Because *Incompatibility
+}
+
+// Derived indicates whether the incompatibility was derived from others,
+// rather than learned directly from a failure.
+func (inc *Incompatibility) Derived() bool {
+	return inc.Cause == nil
+}
+
+// String renders the incompatibility, and everything it was derived from, as
+// a numbered series of explanations, ending with this one.
+func (inc *Incompatibility) String() string {
+	var buf bytes.Buffer
+	writeDerivation(&buf, inc, make(map[*Incompatibility]int))
+	return buf.String()
+}
+
+func writeDerivation(buf *bytes.Buffer, inc *Incompatibility, nums map[*Incompatibility]int) {
+	if _, done := nums[inc]; done {
+		return
+	}
+
+	if !inc.Derived() {
+		nums[inc] = 0
+		fmt.Fprintf(buf, "%s.\n", describeCause(inc.Cause, ProjectIdentifier{}))
+		return
+	}
+
+	// Explain everything this depends on first, so it can be referred to.
+	for _, r := range inc.Rejected {
+		if r.Because != nil && r.Because.Derived() {
+			writeDerivation(buf, r.Because, nums)
+		}
+	}
+
+	n := len(nums) + 1
+	nums[inc] = n
+
+	// Group the versions by the reason they were rejected, preserving the
+	// order in which the reasons were encountered. Reasons are described
+	// without the rejected project's own version, so that versions rejected
+	// for the same reason are grouped together.
+	var order []string
+	groups := make(map[string][]string)
+	for _, r := range inc.Rejected {
+		var reason string
+		switch {
+		case r.Because == nil:
+			reason = "an unknown failure"
+		case r.Because.Derived():
+			reason = fmt.Sprintf("(%d)", nums[r.Because])
+		default:
+			reason = describeCause(r.Because.Cause, inc.Project)
+		}
+
+		if _, has := groups[reason]; !has {
+			order = append(order, reason)
+		}
+		groups[reason] = append(groups[reason], a2vs(atom{id: inc.Project, v: r.Version}))
+	}
+
+	var reasons []string
+	for _, reason := range order {
+		reasons = append(reasons, fmt.Sprintf("%s, ruling out %s", reason, joinAnd(groups[reason])))
+	}
+
+	var requirers []string
+	for _, sel := range inc.RequiredBy {
+		requirers = append(requirers, sel.String())
+	}
+
+	fmt.Fprintf(buf, "(%d) No version of %s can be used: %s. As %s %s %s, ", n, inc.Project.errString(), strings.Join(reasons, "; and "), joinAnd(requirers), pluralize(len(requirers), "requires", "require"), inc.Project.errString())
+
+	var sels []string
+	for _, sel := range inc.Selections {
+		sels = append(sels, sel.String())
+	}
+	switch len(sels) {
+	case 0:
+		buf.WriteString("there is no solution.\n")
+	case 1:
+		fmt.Fprintf(buf, "%s cannot be selected.\n", sels[0])
+	case 2:
+		fmt.Fprintf(buf, "%s are incompatible.\n", joinAnd(sels))
+	default:
+		fmt.Fprintf(buf, "%s cannot all be selected together.\n", joinAnd(sels))
+	}
+}
+
+// describeCause summarizes, in a single clause, the failure from which an
+// incompatibility was learned. Atoms of the subject project are described
+// without their version.
+func describeCause(err error, subject ProjectIdentifier) string {
+	vs := func(a atom) string {
+		if a.id.eq(subject) {
+			return a.id.errString()
+		}
+		return a2vs(a)
+	}
+
+	switch e := err.(type) {
+	case *versionNotAllowedFailure:
+		if len(e.failparent) == 0 {
+			return fmt.Sprintf("the combined constraints on %s allow only %s", e.goal.id.errString(), e.c)
+		}
+		fp := e.failparent[0]
+		return fmt.Sprintf("%s requires %s %s", vs(fp.depender), fp.dep.Ident.errString(), fp.dep.Constraint)
+	case *disjointConstraintFailure:
+		dep := e.goal.dep
+		if len(e.failsib) == 0 {
+			return fmt.Sprintf("%s requires %s %s, while the combined constraints on it allow only %s", vs(e.goal.depender), dep.Ident.errString(), dep.Constraint, e.c)
+		}
+		sib := e.failsib[0]
+		return fmt.Sprintf("%s requires %s %s, while %s requires %s %s", vs(e.goal.depender), dep.Ident.errString(), dep.Constraint, vs(sib.depender), sib.dep.Ident.errString(), sib.dep.Constraint)
+	case *constraintNotAllowedFailure:
+		dep := e.goal.dep
+		return fmt.Sprintf("%s requires %s %s, while %s is selected", vs(e.goal.depender), dep.Ident.errString(), dep.Constraint, vs(atom{id: dep.Ident, v: e.v}))
+	case *depHasProblemPackagesFailure:
+		var pkgs []string
+		for pkg := range e.prob {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+		return fmt.Sprintf("%s imports %s, which %s missing or broken in %s", vs(e.goal.depender), joinAnd(pkgs), pluralize(len(pkgs), "is", "are"), vs(atom{id: e.goal.dep.Ident, v: e.v}))
+	case *checkeeHasProblemPackagesFailure:
+		var pkgs []string
+		deppers := make(map[string]bool)
+		for pkg, ed := range e.failpkg {
+			pkgs = append(pkgs, pkg)
+			for _, d := range ed.deppers {
+				deppers[vs(d)] = true
+			}
+		}
+		sort.Strings(pkgs)
+		var dl []string
+		for d := range deppers {
+			dl = append(dl, d)
+		}
+		sort.Strings(dl)
+		return fmt.Sprintf("%s %s %s, which %s missing or broken in %s", joinAnd(dl), pluralize(len(dl), "imports", "import"), joinAnd(pkgs), pluralize(len(pkgs), "is", "are"), vs(e.goal))
+	case *nonexistentRevisionFailure:
+		return fmt.Sprintf("%s requires %s at revision %s, which does not exist", vs(e.goal.depender), e.goal.dep.Ident.errString(), e.r)
+	case *versionDeniedFailure:
+		if e.rule.Reason == "" {
+			return fmt.Sprintf("%s is denied", vs(e.goal))
+		}
+		return fmt.Sprintf("%s is denied (%s)", vs(e.goal), e.rule.Reason)
+	case *goVersionFailure:
+		return fmt.Sprintf("%s requires Go %s, but the target is %s", vs(e.goal), e.min, e.target)
+	case *noVersionError:
+		return fmt.Sprintf("no version of %s could be selected", e.pn.errString())
+	}
+
+	// Fall back on the first line of whatever the error has to say.
+	return strings.SplitN(err.Error(), "\n", 2)[0]
+}
+
+// incompatibility converts the nogood, and those it was derived from, into an
+// Incompatibility. Nogoods reached more than once are converted only once.
+func (ng *nogood) incompatibility(memo map[*nogood]*Incompatibility) *Incompatibility {
+	if inc, has := memo[ng]; has {
+		return inc
+	}
+
+	inc := &Incompatibility{}
+	memo[ng] = inc
+	for _, t := range ng.terms {
+		inc.Selections = append(inc.Selections, IncompatibleSelection{Project: t.id, Version: t.v})
+	}
+
+	if ng.from == nil {
+		inc.Cause = ng.cause
+		return inc
+	}
+
+	inc.Project = ng.exhausted
+	for _, a := range ng.requiredBy {
+		sel := IncompatibleSelection{Project: a.id, Version: a.v}
+		if sel.Version == rootRev {
+			sel.Version = nil
+		}
+		inc.RequiredBy = append(inc.RequiredBy, sel)
+	}
+	for k, from := range ng.from {
+		rv := RejectedVersion{Version: ng.versions[k]}
+		if from != nil {
+			rv.Because = from.incompatibility(memo)
+		}
+		inc.Rejected = append(inc.Rejected, rv)
+	}
+
+	return inc
+}
+
+func joinAnd(l []string) string {
+	switch len(l) {
+	case 0:
+		return ""
+	case 1:
+		return l[0]
+	}
+	return strings.Join(l[:len(l)-1], ", ") + " and " + l[len(l)-1]
+}
+
+func pluralize(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,84 @@
+package gps
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnsolvableDerivation(t *testing.T) {
+	// Every version of a requires c ^2.0.0, but b requires c ^1.0.0, so a
+	// and b cannot both be selected.
+	fix := basicFixture{
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a *", "b *"),
+			mkDepspec("a 1.0.0", "c ^2.0.0"),
+			mkDepspec("a 1.1.0", "c ^2.0.0"),
+			mkDepspec("b 1.0.0", "c ^1.0.0"),
+			mkDepspec("c 1.0.0"),
+			mkDepspec("c 2.0.0"),
+		},
+	}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+
+	_, err = s.Solve()
+	uerr, ok := err.(*UnsolvableError)
+	if !ok {
+		t.Fatalf("expected an UnsolvableError, got %v", err)
+	}
+
+	inc := uerr.Derivation
+	if len(inc.Selections) != 0 {
+		t.Errorf("expected the final incompatibility to have no selections, got %v", inc.Selections)
+	}
+	if !inc.Derived() || inc.Project.ProjectRoot != "b" {
+		t.Fatalf("expected the final incompatibility to be derived from exhausting b, got %+v", inc)
+	}
+	if len(inc.RequiredBy) != 1 || inc.RequiredBy[0].Version != nil {
+		t.Errorf("expected b to be required by the root alone, got %v", inc.RequiredBy)
+	}
+	if len(inc.Rejected) != 1 || inc.Rejected[0].Because == nil {
+		t.Fatalf("expected one rejected version of b with a known reason, got %+v", inc.Rejected)
+	}
+
+	sub := inc.Rejected[0].Because
+	if !sub.Derived() || sub.Project.ProjectRoot != "a" {
+		t.Fatalf("expected b@1.0.0 to be ruled out by exhausting a, got %+v", sub)
+	}
+	if len(sub.Selections) != 1 || sub.Selections[0].Project.ProjectRoot != "b" {
+		t.Errorf("expected exhausting a to rule out only b, got %v", sub.Selections)
+	}
+	if len(sub.Rejected) != 2 {
+		t.Fatalf("expected both versions of a to be rejected, got %+v", sub.Rejected)
+	}
+	for _, r := range sub.Rejected {
+		if r.Because == nil || r.Because.Derived() {
+			t.Errorf("expected a@%s to be rejected by a direct conflict, got %+v", r.Version, r.Because)
+			continue
+		}
+		if _, ok := r.Because.Cause.(*disjointConstraintFailure); !ok {
+			t.Errorf("expected a@%s to be rejected by a disjointConstraintFailure, got %T", r.Version, r.Because.Cause)
+		}
+	}
+
+	want := []string{
+		"(1) No version of a can be used: a requires c ^2.0.0, while b@1.0.0 requires c ^1.0.0, ruling out a@1.1.0 and a@1.0.0. As (root) requires a, b@1.0.0 cannot be selected.",
+		"(2) No version of b can be used: (1), ruling out b@1.0.0. As (root) requires b, there is no solution.",
+	}
+	if got := strings.TrimSpace(inc.String()); got != strings.Join(want, "\n") {
+		t.Errorf("unexpected derivation text:\n%s", got)
+	}
+	if !strings.Contains(uerr.Error(), want[1]) {
+		t.Errorf("expected the error to include the derivation, got:\n%s", uerr.Error())
+	}
+}
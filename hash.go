@@ -6,7 +6,6 @@ import (
 	"io"
 	"sort"
 	"strconv"
-	"strings"
 
 	"github.com/sdboyer/gps/pkgtree"
 )
@@ -18,6 +17,10 @@ const (
 	hhIgnores     = "-IGNORES-"
 	hhOverrides   = "-OVERRIDES-"
 	hhAnalyzer    = "-ANALYZER-"
+	hhDenied      = "-DENIED-"
+	hhWorkspace   = "-WORKSPACE-"
+	hhTargets     = "-TARGETS-"
+	hhStdLib      = "-STDLIB-"
 )
 
 // HashInputs computes a hash digest of all data in SolveParams and the
@@ -73,13 +76,13 @@ func (s *solver) writeHashingInputs(w io.Writer) {
 		writeString(im)
 	}
 
-	// Add ignores, skipping any that point under the current project root;
-	// those will have already been implicitly incorporated by the import
-	// lister.
+	// Add ignores, skipping any that point under the current project root, or
+	// another workspace root; those will have already been implicitly
+	// incorporated by the import lister.
 	writeString(hhIgnores)
 	ig := make([]string, 0, len(s.rd.ig))
 	for pkg := range s.rd.ig {
-		if !strings.HasPrefix(pkg, s.rd.rpt.ImportRoot) || !isPathPrefixOrEqual(s.rd.rpt.ImportRoot, pkg) {
+		if !s.rd.isLocal(pkg) {
 			ig = append(ig, pkg)
 		}
 	}
@@ -107,6 +110,69 @@ func (s *solver) writeHashingInputs(w io.Writer) {
 	an, av := s.rd.an.Info()
 	writeString(an)
 	writeString(strconv.Itoa(av))
+
+	// The standard library decides which imports are solved for, so the Go
+	// release whose standard library was used, or the heuristic, is written.
+	// Whether dependencies' minimum Go versions are enforced follows from it,
+	// too.
+	writeString(hhStdLib)
+	writeString(s.rd.gover)
+
+	// Deny rules can change the solution just as overrides can. The section is
+	// only written when there are rules, so as not to change the digest of
+	// every existing lock.
+	if len(s.rd.deny) > 0 {
+		writeString(hhDenied)
+		for _, r := range s.rd.deny.asSortedSlice() {
+			writeString(string(r.ProjectRoot))
+			writeString(r.Constraint.typedString())
+		}
+	}
+
+	// Likewise, the other roots in a workspace are only written if there are
+	// any. Their imports and constraints are already incorporated above.
+	if roots := s.rd.workspaceRoots(); len(roots) > 0 {
+		writeString(hhWorkspace)
+		for _, r := range roots {
+			writeString(r)
+		}
+	}
+
+	// Targets determine which imports are followed in dependencies, not just
+	// in the root, so they're written even though the root's imports above
+	// already reflect them. Again, only if there are any.
+	if len(s.rd.targets) > 0 {
+		writeString(hhTargets)
+		for _, t := range canonicalTargets(s.rd.targets) {
+			writeString(t)
+		}
+	}
+}
+
+// canonicalTargets renders the targets as sorted, deduplicated strings, with
+// their tags likewise sorted and deduplicated, so that neither order nor
+// repetition affects the hash.
+func canonicalTargets(targets []pkgtree.Target) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range targets {
+		tags := make(map[string]bool)
+		for _, tag := range t.Tags {
+			tags[tag] = true
+		}
+		t.Tags = make([]string, 0, len(tags))
+		for tag := range tags {
+			t.Tags = append(t.Tags, tag)
+		}
+		sort.Strings(t.Tags)
+
+		if ts := t.String(); !seen[ts] {
+			seen[ts] = true
+			out = append(out, ts)
+		}
+	}
+	sort.Strings(out)
+	return out
 }
 
 // bytes.Buffer wrapper that injects newlines after each call to Write().
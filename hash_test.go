@@ -7,6 +7,9 @@ import (
 	"strings"
 	"testing"
 	"text/tabwriter"
+
+	"github.com/sdboyer/gps/internal"
+	"github.com/sdboyer/gps/pkgtree"
 )
 
 func TestHashInputs(t *testing.T) {
@@ -41,6 +44,8 @@ func TestHashInputs(t *testing.T) {
 		hhAnalyzer,
 		"naive-analyzer",
 		"1",
+		hhStdLib,
+		"heuristic",
 	}
 	for _, v := range elems {
 		h.Write([]byte(v))
@@ -94,6 +99,8 @@ func TestHashInputsReqsIgs(t *testing.T) {
 		hhAnalyzer,
 		"naive-analyzer",
 		"1",
+		hhStdLib,
+		"heuristic",
 	}
 	for _, v := range elems {
 		h.Write([]byte(v))
@@ -138,6 +145,8 @@ func TestHashInputsReqsIgs(t *testing.T) {
 		hhAnalyzer,
 		"naive-analyzer",
 		"1",
+		hhStdLib,
+		"heuristic",
 	}
 	for _, v := range elems {
 		h.Write([]byte(v))
@@ -176,6 +185,8 @@ func TestHashInputsReqsIgs(t *testing.T) {
 		hhAnalyzer,
 		"naive-analyzer",
 		"1",
+		hhStdLib,
+		"heuristic",
 	}
 	for _, v := range elems {
 		h.Write([]byte(v))
@@ -232,6 +243,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -263,6 +276,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -293,6 +308,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -321,6 +338,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -353,6 +372,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -381,6 +402,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -411,6 +434,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -442,6 +467,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -474,6 +501,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 		{
@@ -508,6 +537,8 @@ func TestHashInputsOverrides(t *testing.T) {
 				hhAnalyzer,
 				"naive-analyzer",
 				"1",
+				hhStdLib,
+				"heuristic",
 			},
 		},
 	}
@@ -583,3 +614,205 @@ func diffHashingInputs(s Solver, wnt []string) string {
 	tw.Flush()
 	return buf.String()
 }
+
+func TestHashInputsDeny(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		Deny: []DenyRule{
+			{ProjectRoot: "shared", Constraint: NewVersion("3.6.9"), Reason: "ADV-1"},
+			{ProjectRoot: "a", Constraint: mkSVC("<1.0.0")},
+		},
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+
+	dig := s.HashInputs()
+	h := sha256.New()
+
+	// Reasons have no effect on solving, so are not hashed.
+	elems := []string{
+		hhConstraints,
+		"a",
+		"sv-1.0.0",
+		"b",
+		"sv-1.0.0",
+		hhImportsReqs,
+		"a",
+		"b",
+		hhIgnores,
+		hhOverrides,
+		hhAnalyzer,
+		"naive-analyzer",
+		"1",
+		hhStdLib,
+		"heuristic",
+		hhDenied,
+		"a",
+		"svc-<1.0.0",
+		"shared",
+		"sv-3.6.9",
+	}
+	for _, v := range elems {
+		h.Write([]byte(v))
+	}
+	correct := h.Sum(nil)
+
+	if !bytes.Equal(dig, correct) {
+		t.Errorf("Hashes are not equal. Inputs:\n%s", diffHashingInputs(s, elems))
+	} else if strings.Join(elems, "\n")+"\n" != HashingInputsAsString(s) {
+		t.Errorf("Hashes are equal, but hashing input strings are not:\n%s", diffHashingInputs(s, elems))
+	}
+
+	params.Deny = []DenyRule{{ProjectRoot: "a"}}
+	if _, err = Prepare(params, newdepspecSM(fix.ds, nil)); err == nil {
+		t.Error("expected an error for a deny rule without a constraint")
+	}
+}
+
+func TestHashInputsTargets(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		Targets: []pkgtree.Target{
+			{GOOS: "linux", GOARCH: "arm64", Tags: []string{"netgo", "cgo", "netgo"}},
+			{GOOS: "linux", GOARCH: "amd64"},
+		},
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+
+	dig := s.HashInputs()
+	h := sha256.New()
+
+	// Neither the order of targets, nor that of their tags, matters.
+	elems := []string{
+		hhConstraints,
+		"a",
+		"sv-1.0.0",
+		"b",
+		"sv-1.0.0",
+		hhImportsReqs,
+		"a",
+		"b",
+		hhIgnores,
+		hhOverrides,
+		hhAnalyzer,
+		"naive-analyzer",
+		"1",
+		hhStdLib,
+		"heuristic",
+		hhTargets,
+		"linux/amd64",
+		"linux/arm64 (cgo,netgo)",
+	}
+	for _, v := range elems {
+		h.Write([]byte(v))
+	}
+	correct := h.Sum(nil)
+
+	if !bytes.Equal(dig, correct) {
+		t.Errorf("Hashes are not equal. Inputs:\n%s", diffHashingInputs(s, elems))
+	} else if strings.Join(elems, "\n")+"\n" != HashingInputsAsString(s) {
+		t.Errorf("Hashes are equal, but hashing input strings are not:\n%s", diffHashingInputs(s, elems))
+	}
+
+	// A lock produced for other targets is out of date.
+	params.Targets = params.Targets[1:]
+	s2, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+	if bytes.Equal(dig, s2.HashInputs()) {
+		t.Error("expected solves for different targets to have different input hashes")
+	}
+}
+
+func TestHashInputsGoVersion(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		GoVersion:       "1.9.2",
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+
+	dig := s.HashInputs()
+	h := sha256.New()
+
+	// An explicit Go version also enforces minimum Go versions, but as that
+	// follows from the version, it's written only once.
+	elems := []string{
+		hhConstraints,
+		"a",
+		"sv-1.0.0",
+		"b",
+		"sv-1.0.0",
+		hhImportsReqs,
+		"a",
+		"b",
+		hhIgnores,
+		hhOverrides,
+		hhAnalyzer,
+		"naive-analyzer",
+		"1",
+		hhStdLib,
+		"go1.9",
+	}
+	for _, v := range elems {
+		h.Write([]byte(v))
+	}
+	correct := h.Sum(nil)
+
+	if !bytes.Equal(dig, correct) {
+		t.Errorf("Hashes are not equal. Inputs:\n%s", diffHashingInputs(s, elems))
+	} else if strings.Join(elems, "\n")+"\n" != HashingInputsAsString(s) {
+		t.Errorf("Hashes are equal, but hashing input strings are not:\n%s", diffHashingInputs(s, elems))
+	}
+
+	// Giving the newest Go version explicitly is not the same as the default.
+	params.GoVersion = internal.LatestGoVersion
+	s2, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+	params.GoVersion = ""
+	s3, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+	if bytes.Equal(s2.HashInputs(), s3.HashInputs()) {
+		t.Error("expected an explicit Go version to change the input hash")
+	}
+
+	// The default is the heuristic, so naming it changes nothing.
+	params.GoVersion = pkgtree.StdLibHeuristic
+	s4, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+	if !bytes.Equal(s3.HashInputs(), s4.HashInputs()) {
+		t.Error("expected the heuristic to hash the same as the default")
+	}
+}
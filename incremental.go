@@ -0,0 +1,182 @@
+package gps
+
+import (
+	"fmt"
+
+	"github.com/sdboyer/gps/pkgtree"
+)
+
+// atomData is the information about an atom that the solver retrieves from
+// the SourceManager.
+type atomData struct {
+	m     Manifest
+	l     Lock
+	ptree *pkgtree.PackageTree
+}
+
+// atomKey returns the key under which the data for the atom is retained, or
+// the empty string if the version is not immutable, and so cannot safely be
+// retained.
+func atomKey(id ProjectIdentifier, v Version) string {
+	switch v.(type) {
+	case Revision, PairedVersion:
+		return string(id.ProjectRoot) + " " + id.Source + "@" + v.typedString()
+	}
+	return ""
+}
+
+// solveRecord retains the inputs to a successful solve run, and the data
+// retrieved for the selected atoms, so that a later run on changed inputs
+// can build on it.
+type solveRecord struct {
+	// The root's constraints, overrides and deny rules, rendered as strings
+	// for comparison, and indexed by project root.
+	constraints map[ProjectRoot]string
+	// The root's external imports, including required packages.
+	imports map[string]bool
+	// The name and version of the ProjectAnalyzer used.
+	an string
+	// The data retrieved for each selected atom, by atomKey.
+	atoms map[string]atomData
+}
+
+// recordInputs captures the solver inputs against which a later run's
+// changes are identified.
+func (rd rootdata) recordInputs() *solveRecord {
+	r := &solveRecord{
+		constraints: make(map[ProjectRoot]string),
+		imports:     make(map[string]bool),
+	}
+
+	add := func(pr ProjectRoot, s string) {
+		r.constraints[pr] += s + ";"
+	}
+	for _, wc := range rd.combineConstraints() {
+		add(wc.Ident.ProjectRoot, fmt.Sprintf("dep %s %s", wc.Ident.Source, wc.Constraint.typedString()))
+	}
+	for pr, pp := range rd.ovr {
+		c := "nil"
+		if pp.Constraint != nil {
+			c = pp.Constraint.typedString()
+		}
+		add(pr, fmt.Sprintf("ovr %s %s", pp.Source, c))
+	}
+	for _, rule := range rd.deny.asSortedSlice() {
+		add(rule.ProjectRoot, "deny "+rule.Constraint.typedString())
+	}
+
+	for _, imp := range rd.externalImportList() {
+		r.imports[imp] = true
+	}
+
+	name, vers := rd.an.Info()
+	r.an = fmt.Sprintf("%s.%v", name, vers)
+	return r
+}
+
+// invalidated determines which of the projects in the previous solution must
+// be solved afresh, given the current inputs: those whose constraints or
+// imported packages changed, those asked to change, and all the projects
+// reachable from any of them in the previous dependency graph.
+func (prev *solveRecord) invalidated(cur *solveRecord, g *depGraph, chng map[ProjectRoot]struct{}) map[ProjectRoot]bool {
+	inv := make(map[ProjectRoot]bool)
+	var queue []ProjectRoot
+	mark := func(pr ProjectRoot) {
+		if _, has := g.versions[pr]; has && !inv[pr] {
+			inv[pr] = true
+			queue = append(queue, pr)
+		}
+	}
+
+	for pr := range chng {
+		mark(pr)
+	}
+	for pr, s := range cur.constraints {
+		if prev.constraints[pr] != s {
+			mark(pr)
+		}
+	}
+	for pr := range prev.constraints {
+		if _, has := cur.constraints[pr]; !has {
+			mark(pr)
+		}
+	}
+
+	// An import that was added or removed changes the packages needed from
+	// the project that contains it. If no project contained it before, it
+	// belongs to a project that's new altogether.
+	changedImport := func(path string) {
+		if _, ipr, has := g.roots.LongestPrefix(path); has && isPathPrefixOrEqual(string(ipr.(ProjectRoot)), path) {
+			mark(ipr.(ProjectRoot))
+		}
+	}
+	for imp := range cur.imports {
+		if !prev.imports[imp] {
+			changedImport(imp)
+		}
+	}
+	for imp := range prev.imports {
+		if !cur.imports[imp] {
+			changedImport(imp)
+		}
+	}
+
+	deps := make(map[ProjectRoot][]ProjectRoot)
+	for pr, dcs := range g.constraints {
+		for _, dc := range dcs {
+			deps[dc.Depender] = append(deps[dc.Depender], pr)
+		}
+	}
+	for len(queue) > 0 {
+		pr := queue[0]
+		queue = queue[1:]
+		for _, dpr := range deps[pr] {
+			mark(dpr)
+		}
+	}
+
+	return inv
+}
+
+// buildFrom prepares the solver to build on the previous solution: projects
+// it invalidates are marked for change, and the retained data for the rest
+// is reused.
+func (s *solver) buildFrom(prev retainedSolution) {
+	if prev.rec == nil || prev.g == nil {
+		return
+	}
+
+	cur := s.rd.recordInputs()
+	inv := prev.rec.invalidated(cur, prev.g, s.rd.chng)
+	for pr := range inv {
+		s.rd.chng[pr] = struct{}{}
+	}
+
+	if cur.an != prev.rec.an {
+		// Manifests and locks from a different analyzer can't be trusted.
+		return
+	}
+	for _, lp := range prev.p {
+		if inv[lp.pi.ProjectRoot] {
+			continue
+		}
+		key := atomKey(lp.pi, lp.Version())
+		if d, has := prev.rec.atoms[key]; has && key != "" {
+			s.atoms[key] = d
+		}
+	}
+}
+
+// recordSolve retains the inputs to the completed run, along with the data
+// for the selected atoms.
+func (s *solver) recordSolve(projs []LockedProject) *solveRecord {
+	rec := s.rd.recordInputs()
+	rec.atoms = make(map[string]atomData, len(projs))
+	for _, lp := range projs {
+		key := atomKey(lp.pi, lp.Version())
+		if d, has := s.atoms[key]; has && key != "" {
+			rec.atoms[key] = d
+		}
+	}
+	return rec
+}
@@ -0,0 +1,152 @@
+package gps
+
+import (
+	"context"
+	"testing"
+)
+
+// gmalSM is a fixture SourceManager that counts requests for manifests and
+// locks, by project.
+type gmalSM struct {
+	*depspecSourceManager
+	calls map[ProjectRoot]int
+}
+
+func (sm *gmalSM) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	sm.calls[id.ProjectRoot]++
+	return sm.depspecSourceManager.GetManifestAndLockContext(ctx, id, v, an)
+}
+
+func TestSolveFromPrevious(t *testing.T) {
+	before := basicFixture{
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a *", "c ^1.0.0"),
+			mkDepspec("a 1.0.0 arev1", "b *"),
+			mkDepspec("b 1.0.0 brev1"),
+			mkDepspec("c 1.0.0 crev1", "d *"),
+			mkDepspec("d 1.0.0 drev1"),
+		},
+	}
+	// The constraint on c changes, and new versions of everything appear.
+	after := basicFixture{
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a *", "c ^1.1.0"),
+			mkDepspec("a 1.0.0 arev1", "b *"),
+			mkDepspec("a 1.1.0 arev2", "b *"),
+			mkDepspec("b 1.0.0 brev1"),
+			mkDepspec("b 1.1.0 brev2"),
+			mkDepspec("c 1.0.0 crev1", "d *"),
+			mkDepspec("c 1.1.0 crev2", "d *"),
+			mkDepspec("d 1.0.0 drev1"),
+			mkDepspec("d 1.1.0 drev2"),
+		},
+	}
+
+	solve := func(fix basicFixture, l Lock, prev Solution) (Solution, map[ProjectRoot]int) {
+		sm := &gmalSM{
+			depspecSourceManager: newdepspecSM(fix.ds, nil),
+			calls:                make(map[ProjectRoot]int),
+		}
+		params := SolveParameters{
+			RootDir:             string(fix.ds[0].n),
+			RootPackageTree:     fix.rootTree(),
+			Manifest:            fix.rootmanifest(),
+			ProjectAnalyzer:     naiveAnalyzer{},
+			Lock:                l,
+			Previous:            prev,
+			Retain:              true,
+			PrefetchConcurrency: -1,
+		}
+
+		s, err := Prepare(params, sm)
+		if err != nil {
+			t.Fatalf("unexpected error while preparing solver: %s", err)
+		}
+		soln, err := s.Solve()
+		if err != nil {
+			t.Fatalf("unexpected error while solving: %s", err)
+		}
+		return soln, sm.calls
+	}
+
+	versions := func(soln Solution) map[ProjectRoot]string {
+		m := make(map[ProjectRoot]string)
+		for _, lp := range soln.Projects() {
+			m[lp.Ident().ProjectRoot] = lp.Version().String()
+		}
+		return m
+	}
+
+	prev, _ := solve(before, nil, nil)
+
+	// With only the lock to go on, d stays put even though c moved.
+	locked, _ := solve(after, prev, nil)
+	want := map[ProjectRoot]string{"a": "1.0.0", "b": "1.0.0", "c": "1.1.0", "d": "1.0.0"}
+	for pr, v := range versions(locked) {
+		if want[pr] != v {
+			t.Errorf("(lock) expected %s at %s, got %s", pr, want[pr], v)
+		}
+	}
+
+	// Building on the previous solution, everything reachable from c is
+	// solved afresh, while the rest is kept, and reused without asking the
+	// SourceManager about it again.
+	soln, calls := solve(after, nil, prev)
+	want = map[ProjectRoot]string{"a": "1.0.0", "b": "1.0.0", "c": "1.1.0", "d": "1.1.0"}
+	got := versions(soln)
+	if len(got) != len(want) {
+		t.Errorf("(previous) expected %v projects, got %v", len(want), got)
+	}
+	for pr, v := range got {
+		if want[pr] != v {
+			t.Errorf("(previous) expected %s at %s, got %s", pr, want[pr], v)
+		}
+	}
+	for _, pr := range []ProjectRoot{"a", "b"} {
+		if calls[pr] != 0 {
+			t.Errorf("(previous) expected manifest of %s to be reused, but it was requested %v times", pr, calls[pr])
+		}
+	}
+	for _, pr := range []ProjectRoot{"c", "d"} {
+		if calls[pr] == 0 {
+			t.Errorf("(previous) expected manifest of %s to be requested afresh", pr)
+		}
+	}
+
+	// Nothing has changed, so a solve building on that one again touches
+	// nothing.
+	_, calls = solve(after, nil, soln)
+	if len(calls) != 0 {
+		t.Errorf("(unchanged) expected no manifests to be requested, got %v", calls)
+	}
+
+	// Only a Solution that came from a Solver can be built on.
+	params := SolveParameters{
+		RootDir:         string(after.ds[0].n),
+		RootPackageTree: after.rootTree(),
+		Manifest:        after.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		Previous:        struct{ Solution }{soln},
+	}
+	if _, err := Prepare(params, newdepspecSM(after.ds, nil)); err == nil {
+		t.Error("expected an error from a previous solution not returned by a Solver")
+	}
+
+	// Nor can one whose detail wasn't retained.
+	params.Previous = nil
+	s, err := Prepare(params, newdepspecSM(after.ds, nil))
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+	plain, err := s.Solve()
+	if err != nil {
+		t.Fatalf("unexpected error while solving: %s", err)
+	}
+	if _, ok := plain.(RetainedSolution); ok {
+		t.Error("solution should not retain detail unless asked to")
+	}
+	params.Previous = plain
+	if _, err = Prepare(params, newdepspecSM(after.ds, nil)); err == nil {
+		t.Error("expected an error from a previous solution without retained detail")
+	}
+}
@@ -5,24 +5,74 @@ import "testing"
 func TestIsStdLib(t *testing.T) {
 	fix := []struct {
 		ip string
+		v  string
 		is bool
 	}{
-		{"appengine", true},
-		{"net/http", true},
-		{"github.com/anything", false},
-		{"foo", true},
+		{"appengine", StdLibHeuristic, true},
+		{"net/http", StdLibHeuristic, true},
+		{"github.com/anything", StdLibHeuristic, false},
+		{"foo", StdLibHeuristic, true},
+		{"appengine", "", true},
+		{"net/http", "", true},
+		{"github.com/anything", "", false},
+		{"foo", "", true},
+		{"company/lib", "go1.9", false},
+		{"C", "go1", true},
+		{"C", "", true},
+		{"unsafe", "go1", true},
+		{"context", "go1.6", false},
+		{"context", "go1.7", true},
+		{"context", "", true},
+		{"context", LatestGoVersion, true},
+		{"math/bits", "go1.8", false},
+		{"math/bits", "go1.9", true},
+		{"internal/cpu", LatestGoVersion, false},
 	}
 
 	for _, f := range fix {
-		r := doIsStdLib(f.ip)
+		r := doIsStdLib(f.ip, f.v)
 		if r != f.is {
 			if r {
-				t.Errorf("%s was marked stdlib but should not have been", f.ip)
+				t.Errorf("%s was marked stdlib in %q but should not have been", f.ip, f.v)
 			} else {
-				t.Errorf("%s was not marked stdlib but should have been", f.ip)
+				t.Errorf("%s was not marked stdlib in %q but should have been", f.ip, f.v)
 
 			}
 		}
 	}
 }
 
+func TestParseGoVersion(t *testing.T) {
+	fix := []struct {
+		in, out string
+		err     bool
+	}{
+		{"", StdLibHeuristic, false},
+		{StdLibHeuristic, StdLibHeuristic, false},
+		{"go1", "go1", false},
+		{"1.0", "go1", false},
+		{"1.9", "go1.9", false},
+		{"go1.9", "go1.9", false},
+		{"go1.9.2", "go1.9", false},
+		{"go1.10rc1", "go1.10", false},
+		{LatestGoVersion, LatestGoVersion, false},
+		{"go1.999", "", true},
+		{"go2", "", true},
+		{"devel", "", true},
+	}
+
+	for _, f := range fix {
+		out, err := ParseGoVersion(f.in)
+		if f.err {
+			if err == nil {
+				t.Errorf("expected an error parsing %q, got %q", f.in, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %s", f.in, err)
+		} else if out != f.out {
+			t.Errorf("expected %q to parse to %q, got %q", f.in, f.out, out)
+		}
+	}
+}
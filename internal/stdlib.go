@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StdLibHeuristic, given in place of a Go version, selects the heuristic that
+// treats any import path whose first element lacks a dot as being in the
+// standard library, rather than the packages of any particular Go release.
+const StdLibHeuristic = "heuristic"
+
+// stdlibAdded lists the importable packages added to the standard library in
+// each Go release, indexed by minor version. It is derived from the api/*.txt
+// files in the Go distribution; no package has been removed since go1.
+var stdlibAdded = [...]string{
+	0: `archive/tar archive/zip bufio bytes compress/bzip2 compress/flate
+		compress/gzip compress/lzw compress/zlib container/heap container/list
+		container/ring crypto crypto/aes crypto/cipher crypto/des crypto/dsa
+		crypto/ecdsa crypto/elliptic crypto/hmac crypto/md5 crypto/rand
+		crypto/rc4 crypto/rsa crypto/sha1 crypto/sha256 crypto/sha512
+		crypto/subtle crypto/tls crypto/x509 crypto/x509/pkix database/sql
+		database/sql/driver debug/dwarf debug/elf debug/gosym debug/macho
+		debug/pe encoding/ascii85 encoding/asn1 encoding/base32 encoding/base64
+		encoding/binary encoding/csv encoding/gob encoding/hex encoding/json
+		encoding/pem encoding/xml errors expvar flag fmt go/ast go/build go/doc
+		go/parser go/printer go/scanner go/token hash hash/adler32 hash/crc32
+		hash/crc64 hash/fnv html html/template image image/color image/draw
+		image/gif image/jpeg image/png index/suffixarray io io/ioutil log
+		log/syslog math math/big math/cmplx math/rand mime mime/multipart net
+		net/http net/http/cgi net/http/fcgi net/http/httptest net/http/httputil
+		net/http/pprof net/mail net/rpc net/rpc/jsonrpc net/smtp net/textproto
+		net/url os os/exec os/signal os/user path path/filepath reflect regexp
+		regexp/syntax runtime runtime/cgo runtime/debug runtime/pprof sort
+		strconv strings sync sync/atomic syscall testing testing/iotest
+		testing/quick text/scanner text/tabwriter text/template
+		text/template/parse time unicode unicode/utf16 unicode/utf8 unsafe`,
+	1:  `go/format net/http/cookiejar runtime/race`,
+	2:  `encoding image/color/palette`,
+	3:  `debug/plan9obj`,
+	4:  "",
+	5:  `go/constant go/importer go/types mime/quotedprintable runtime/trace`,
+	6:  "",
+	7:  `context net/http/httptrace`,
+	8:  `plugin`,
+	9:  `math/bits`,
+	10: "",
+	11: `syscall/js`,
+	12: "",
+	13: `crypto/ed25519`,
+	14: `hash/maphash`,
+	15: `time/tzdata`,
+	16: `embed go/build/constraint io/fs runtime/metrics testing/fstest`,
+	17: "",
+	18: `debug/buildinfo net/netip`,
+	19: `go/doc/comment`,
+	20: `crypto/ecdh runtime/coverage`,
+	21: `cmp log/slog maps slices testing/slogtest`,
+	22: `go/version math/rand/v2`,
+	23: `iter structs unique`,
+	24: `crypto/fips140 crypto/hkdf crypto/mlkem crypto/pbkdf2 crypto/sha3 weak`,
+	25: `testing/synctest`,
+	26: `crypto/hpke crypto/mlkem/mlkemtest testing/cryptotest`,
+	27: `crypto/mldsa encoding/json/jsontext encoding/json/v2 uuid`,
+}
+
+var (
+	// stdlibSince maps each standard library package to the minor version of
+	// the Go release in which it was added.
+	stdlibSince = make(map[string]int)
+
+	// LatestGoVersion is the newest Go release whose standard library is
+	// known.
+	LatestGoVersion = fmt.Sprintf("go1.%d", len(stdlibAdded)-1)
+)
+
+func init() {
+	for minor, pkgs := range stdlibAdded {
+		for _, pkg := range strings.Fields(pkgs) {
+			stdlibSince[pkg] = minor
+		}
+	}
+}
+
+// ParseGoVersion normalizes a Go release, such as "1.9", "go1.9" or "go1.9.2",
+// to the form "go1.9", returning an error if its standard library is not
+// known. The empty string is taken to mean StdLibHeuristic, which is
+// returned as is.
+func ParseGoVersion(v string) (string, error) {
+	switch v {
+	case "", StdLibHeuristic:
+		return StdLibHeuristic, nil
+	}
+
+	minor, err := goMinor(v)
+	if err != nil {
+		return "", err
+	}
+	if minor == 0 {
+		return "go1", nil
+	}
+	return fmt.Sprintf("go1.%d", minor), nil
+}
+
+// GoMinor returns the minor version of a Go 1 release, such as "1.9",
+// "go1.9" or "go1.9.2", whether or not its standard library is known.
+func GoMinor(v string) (int, error) {
+	s := strings.TrimPrefix(v, "go")
+	if s == "1" {
+		return 0, nil
+	}
+	if !strings.HasPrefix(s, "1.") {
+		return 0, fmt.Errorf("%q is not a Go 1 release", v)
+	}
+
+	s = s[2:]
+	if i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		s = s[:i]
+	}
+	minor, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a Go 1 release", v)
+	}
+	return minor, nil
+}
+
+// goMinor returns the minor version of a Go release whose standard library is
+// known.
+func goMinor(v string) (int, error) {
+	minor, err := GoMinor(v)
+	if err != nil {
+		return 0, err
+	}
+	if minor >= len(stdlibAdded) {
+		return 0, fmt.Errorf("the standard library of %s is not known; the newest known is %s", v, LatestGoVersion)
+	}
+	return minor, nil
+}
+
+// IsStdLib reports whether the import path is of a package in the standard
+// library of the given Go release, as accepted by ParseGoVersion; if the
+// release is empty, the heuristic is used. The pseudo-package "C", used by
+// cgo, is always included.
+//
+// It is stored as a var so that tests can swap it out. Ugh globals, ugh.
+var IsStdLib = doIsStdLib
+
+func doIsStdLib(path, goVersion string) bool {
+	if path == "C" {
+		return true
+	}
+
+	switch goVersion {
+	case "", StdLibHeuristic:
+		return isStdLibHeuristic(path)
+	}
+
+	minor, err := goMinor(goVersion)
+	if err != nil {
+		minor = len(stdlibAdded) - 1
+	}
+	since, has := stdlibSince[path]
+	return has && since <= minor
+}
@@ -94,26 +94,32 @@ func TestSourceManagerInit(t *testing.T) {
 		t.Errorf("Unexpected error on SourceManager creation: %s", err)
 	}
 
-	_, err = NewSourceManager(cpath)
-	if err == nil {
-		t.Errorf("Creating second SourceManager should have failed due to file lock contention")
-	} else if te, ok := err.(CouldNotCreateLockError); !ok {
-		t.Errorf("Should have gotten CouldNotCreateLockError error type, but got %T", te)
+	// A second SourceManager may share the cache.
+	sm2, err := NewSourceManager(cpath)
+	if err != nil {
+		t.Errorf("Creating second SourceManager on the same cache should have succeeded, but failed with err %s", err)
+	} else {
+		sm2.Release()
 	}
 
 	if _, err = os.Stat(path.Join(cpath, "sm.lock")); err != nil {
 		t.Errorf("Global cache lock file not created correctly")
 	}
 
+	// An exclusive holder of the cache lock must prevent creation.
+	xl := newCacheLock(path.Join(cpath, "sm.lock"))
 	sm.Release()
-	err = removeAll(cpath)
-	if err != nil {
-		t.Errorf("removeAll failed: %s", err)
+	if err = xl.tryLock(true); err != nil {
+		t.Fatalf("Could not take exclusive cache lock after Release(): %s", err)
 	}
 
-	if _, err = os.Stat(path.Join(cpath, "sm.lock")); !os.IsNotExist(err) {
-		t.Fatalf("Global cache lock file not cleared correctly on Release()")
+	_, err = NewSourceManager(cpath)
+	if err == nil {
+		t.Errorf("Creating SourceManager should have failed due to exclusive lock on cache")
+	} else if te, ok := err.(CouldNotCreateLockError); !ok {
+		t.Errorf("Should have gotten CouldNotCreateLockError error type, but got %T", te)
 	}
+	xl.close()
 
 	// Set another one up at the same spot now, just to be sure
 	sm, err = NewSourceManager(cpath)
@@ -128,6 +134,45 @@ func TestSourceManagerInit(t *testing.T) {
 	}
 }
 
+func TestLayeredSourceManagerInit(t *testing.T) {
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer removeAll(cpath)
+
+	top, lower := filepath.Join(cpath, "top"), filepath.Join(cpath, "lower")
+	_, err = NewLayeredSourceManager(top, lower)
+	if err == nil {
+		t.Error("Expected error when read-only layer does not exist")
+	}
+
+	if err = os.MkdirAll(filepath.Join(lower, "sources", "foo"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := NewLayeredSourceManager(top, lower)
+	if err != nil {
+		t.Fatalf("Unexpected error on SourceManager creation: %s", err)
+	}
+	defer sm.Release()
+
+	if sm.cachedir != top {
+		t.Errorf("Expected top layer %s to be the writable cachedir, got %s", top, sm.cachedir)
+	}
+
+	if p, has := sm.layers.findLower(filepath.Join("sources", "foo")); !has || p != filepath.Join(lower, "sources", "foo") {
+		t.Errorf("Expected to find source in read-only layer, got %q (%v)", p, has)
+	}
+	if _, has := sm.layers.findLower(filepath.Join("sources", "bar")); has {
+		t.Error("Should not have found nonexistent source in read-only layer")
+	}
+
+	if _, err = os.Stat(filepath.Join(lower, "sm.lock")); !os.IsNotExist(err) {
+		t.Error("No lock file should be created in read-only layer")
+	}
+}
+
 func TestSourceInit(t *testing.T) {
 	// This test is a bit slow, skip it on -short
 	if testing.Short() {
@@ -722,10 +767,12 @@ func TestSignalHandling(t *testing.T) {
 		t.Error("Releasing flag did not get set")
 	}
 
-	lpath := filepath.Join(sm.cachedir, "sm.lock")
-	if _, err := os.Stat(lpath); err == nil {
-		t.Fatal("Expected error on statting what should be an absent lock file")
+	// The shared cache lock must have been dropped by the release.
+	xl := newCacheLock(filepath.Join(sm.cachedir, "sm.lock"))
+	if err := xl.tryLock(true); err != nil {
+		t.Fatalf("Expected to be able to exclusively lock cache after signal-triggered release: %s", err)
 	}
+	xl.close()
 	clean()
 
 	// Test again, this time with a running call
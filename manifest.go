@@ -24,6 +24,18 @@ type Manifest interface {
 	TestDependencyConstraints() ProjectConstraints
 }
 
+// GoVersionManifest extends Manifest for projects that declare the oldest Go
+// release they can be built with. When a Go version is given in the
+// SolveParameters, the solver rejects versions of dependencies whose
+// manifests declare a newer one.
+type GoVersionManifest interface {
+	Manifest
+
+	// MinimumGoVersion returns the oldest Go release, such as "go1.9", that
+	// can build the project, or the empty string if there is no minimum.
+	MinimumGoVersion() string
+}
+
 // RootManifest extends Manifest to add special controls over solving that are
 // only afforded to the root project.
 type RootManifest interface {
@@ -67,9 +79,12 @@ type RootManifest interface {
 // tool's idioms.
 type SimpleManifest struct {
 	Deps, TestDeps ProjectConstraints
+	// MinGoVersion is the oldest Go release that can build the project, if
+	// any.
+	MinGoVersion string
 }
 
-var _ Manifest = SimpleManifest{}
+var _ GoVersionManifest = SimpleManifest{}
 
 // DependencyConstraints returns the project's dependencies.
 func (m SimpleManifest) DependencyConstraints() ProjectConstraints {
@@ -81,6 +96,11 @@ func (m SimpleManifest) TestDependencyConstraints() ProjectConstraints {
 	return m.TestDeps
 }
 
+// MinimumGoVersion returns the oldest Go release that can build the project.
+func (m SimpleManifest) MinimumGoVersion() string {
+	return m.MinGoVersion
+}
+
 // simpleRootManifest exists so that we have a safe value to swap into solver
 // params when a nil Manifest is provided.
 //
@@ -151,6 +171,9 @@ func prepManifest(m Manifest) SimpleManifest {
 		Deps:     make(ProjectConstraints, len(deps)),
 		TestDeps: make(ProjectConstraints, len(ddeps)),
 	}
+	if gm, ok := m.(GoVersionManifest); ok {
+		rm.MinGoVersion = gm.MinimumGoVersion()
+	}
 
 	for k, d := range deps {
 		// A zero-value ProjectProperties is equivalent to one with an
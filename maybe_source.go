@@ -93,13 +93,16 @@ func (m maybeGitSource) try(ctx context.Context, cachedir string, c singleSource
 
 	// Pinging invokes the same action as calling listVersions, so just do that.
 	var vl []PairedVersion
-	err = superv.do(ctx, "git:lv:maybe", ctListVersions, func(ctx context.Context) (err error) {
+	err = superv.doHost(ctx, m.url.Host, "git:lv:maybe", ctListVersions, func(ctx context.Context) (err error) {
 		if vl, err = src.listVersions(ctx); err != nil {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
 	if err != nil {
+		if state, ok := trySnapshot(path, r.CheckLocal(), c); ok {
+			return src, state, nil
+		}
 		return nil, 0, err
 	}
 
@@ -151,13 +154,16 @@ func (m maybeGopkginSource) try(ctx context.Context, cachedir string, c singleSo
 	}
 
 	var vl []PairedVersion
-	err = superv.do(ctx, "git:lv:maybe", ctListVersions, func(ctx context.Context) (err error) {
+	err = superv.doHost(ctx, m.url.Host, "git:lv:maybe", ctListVersions, func(ctx context.Context) (err error) {
 		if vl, err = src.listVersions(ctx); err != nil {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
 	if err != nil {
+		if state, ok := trySnapshot(path, r.CheckLocal(), c); ok {
+			return src, state, nil
+		}
 		return nil, 0, err
 	}
 
@@ -188,13 +194,22 @@ func (m maybeBzrSource) try(ctx context.Context, cachedir string, c singleSource
 		return nil, 0, unwrapVcsErr(err)
 	}
 
-	err = superv.do(ctx, "bzr:ping", ctSourcePing, func(ctx context.Context) error {
+	src := &bzrSource{
+		baseVCSSource: baseVCSSource{
+			repo: &bzrRepo{r},
+		},
+	}
+
+	err = superv.doHost(ctx, m.url.Host, "bzr:ping", ctSourcePing, func(ctx context.Context) error {
 		if !r.Ping() {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
 	if err != nil {
+		if state, ok := trySnapshot(path, r.CheckLocal(), c); ok {
+			return src, state, nil
+		}
 		return nil, 0, err
 	}
 
@@ -203,12 +218,6 @@ func (m maybeBzrSource) try(ctx context.Context, cachedir string, c singleSource
 		state |= sourceExistsLocally
 	}
 
-	src := &bzrSource{
-		baseVCSSource: baseVCSSource{
-			repo: &bzrRepo{r},
-		},
-	}
-
 	return src, state, nil
 }
 
@@ -229,13 +238,22 @@ func (m maybeHgSource) try(ctx context.Context, cachedir string, c singleSourceC
 		return nil, 0, unwrapVcsErr(err)
 	}
 
-	err = superv.do(ctx, "hg:ping", ctSourcePing, func(ctx context.Context) error {
+	src := &hgSource{
+		baseVCSSource: baseVCSSource{
+			repo: &hgRepo{r},
+		},
+	}
+
+	err = superv.doHost(ctx, m.url.Host, "hg:ping", ctSourcePing, func(ctx context.Context) error {
 		if !r.Ping() {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
 	if err != nil {
+		if state, ok := trySnapshot(path, r.CheckLocal(), c); ok {
+			return src, state, nil
+		}
 		return nil, 0, err
 	}
 
@@ -244,12 +262,6 @@ func (m maybeHgSource) try(ctx context.Context, cachedir string, c singleSourceC
 		state |= sourceExistsLocally
 	}
 
-	src := &hgSource{
-		baseVCSSource: baseVCSSource{
-			repo: &hgRepo{r},
-		},
-	}
-
 	return src, state, nil
 }
 
@@ -0,0 +1,276 @@
+package gps
+
+import (
+	"sort"
+)
+
+// solveMVS performs minimal version selection, as an alternative to the
+// backtracking search performed by solve().
+//
+// Each dependency constraint is satisfied by its minimal version - the lowest
+// version, in downgrade order, that the constraint admits. For each project,
+// the maximum of the minimal versions required of it is selected. Because
+// selected versions only ever move up, and only in response to a constraint,
+// the result depends solely on the constraints in play and not on whatever
+// has since been released upstream.
+//
+// Selecting a higher version of a project can bring in new requirements, so
+// the selection is recomputed from the root until it stops changing. Finally,
+// every constraint on each selected project is checked against its selected
+// version; unlike the minimums, upper bounds are not resolved by searching,
+// and so violating one fails the solve.
+func (s *solver) solveMVS() (map[atom]map[string]struct{}, error) {
+	s.mtr.push("mvs")
+	defer s.mtr.pop()
+
+	root := s.rd.rootAtom().a
+	rootdeps, err := s.intersectConstraintsWithImports(s.rd.combineConstraints(), s.rd.externalImportList())
+	if err != nil {
+		return nil, err
+	}
+
+	// The selected version of each project, which only ever increases.
+	sel := make(map[ProjectRoot]atom)
+	// Memoized minimal versions, keyed by identifier and constraint.
+	mins := make(map[string]Version)
+
+	var pkgs map[ProjectRoot]map[string]struct{}
+	var deps map[ProjectRoot][]dependency
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.attempts++
+		s.bgt.countAttempt(s.attempts)
+
+		var changed bool
+		var queue []ProjectRoot
+		queued := make(map[ProjectRoot]bool)
+		pkgs = make(map[ProjectRoot]map[string]struct{})
+		deps = make(map[ProjectRoot][]dependency)
+
+		visit := func(depender atom, cdeps []completeDep) error {
+			sort.Sort(completeDepsByRoot(cdeps))
+			for _, cdep := range cdeps {
+				pr := cdep.Ident.ProjectRoot
+				// Root can come back up here if there's a project-level cycle.
+				if s.rd.isRoot(pr) {
+					continue
+				}
+
+				dep := dependency{depender: depender, dep: cdep}
+				cur, has := sel[pr]
+				if has && !cur.id.eq(cdep.Ident) {
+					return &sourceMismatchFailure{
+						shared:   pr,
+						current:  cur.id.normalizedSource(),
+						mismatch: cdep.Ident.normalizedSource(),
+						sel:      deps[pr],
+						prob:     depender,
+					}
+				}
+
+				min, err := s.minVersionFor(dep, mins)
+				if err != nil {
+					return err
+				}
+				if !has || vLess(cur.v, min, true) {
+					sel[pr] = atom{id: cdep.Ident, v: min}
+					changed = true
+				}
+				deps[pr] = append(deps[pr], dep)
+
+				pm, has := pkgs[pr]
+				if !has {
+					pm = make(map[string]struct{})
+					pkgs[pr] = pm
+				}
+				for _, pkg := range cdep.pl {
+					if _, has := pm[pkg]; !has {
+						pm[pkg] = struct{}{}
+						if !queued[pr] {
+							queued[pr] = true
+							queue = append(queue, pr)
+						}
+					}
+				}
+			}
+			return nil
+		}
+
+		if err := visit(root, rootdeps); err != nil {
+			return nil, err
+		}
+
+		for len(queue) > 0 {
+			if err := s.ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			pr := queue[0]
+			queue = queue[1:]
+			queued[pr] = false
+
+			pl := make([]string, 0, len(pkgs[pr]))
+			for pkg := range pkgs[pr] {
+				pl = append(pl, pkg)
+			}
+			sort.Strings(pl)
+
+			a := sel[pr]
+			ipl, cdeps, err := s.getImportsAndConstraintsOf(atomWithPackages{a: a, pl: pl})
+			if err != nil {
+				return nil, err
+			}
+			// Internally reachable packages are selected, too, but as they're
+			// already accounted for in cdeps, there's no need to revisit.
+			for _, pkg := range ipl {
+				pkgs[pr][pkg] = struct{}{}
+			}
+
+			if err := visit(a, cdeps); err != nil {
+				return nil, err
+			}
+		}
+
+		if s.bgt.limited() {
+			// Selections only ever move up, so the latest pass got furthest.
+			projs := make(map[atom]map[string]struct{}, len(pkgs))
+			for pr, pm := range pkgs {
+				projs[sel[pr]] = pm
+			}
+			s.bgt.deepest = partialLock(projs)
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	// The selection is stable; make sure it satisfies all the constraints
+	// that were reached from it.
+	roots := make([]string, 0, len(deps))
+	for pr := range deps {
+		roots = append(roots, string(pr))
+	}
+	sort.Strings(roots)
+
+	projs := make(map[atom]map[string]struct{})
+	for _, r := range roots {
+		pr := ProjectRoot(r)
+		a := sel[pr]
+
+		var failparent []dependency
+		c := Any()
+		for _, dep := range deps[pr] {
+			c = s.vUnify.intersect(a.id, c, dep.dep.Constraint)
+			if !s.vUnify.matches(a.id, dep.dep.Constraint, a.v) {
+				failparent = append(failparent, dep)
+			}
+		}
+		if len(failparent) > 0 {
+			return nil, &versionNotAllowedFailure{
+				goal:       a,
+				failparent: failparent,
+				c:          c,
+			}
+		}
+
+		projs[a] = pkgs[pr]
+
+		// Record the selection, as the search would have.
+		pl := make([]string, 0, len(pkgs[pr]))
+		for pkg := range pkgs[pr] {
+			pl = append(pl, pkg)
+		}
+		sort.Strings(pl)
+		s.sel.pushSelection(atomWithPackages{a: a, pl: pl}, false)
+		s.sel.setDependenciesOn(a.id, deps[pr])
+	}
+
+	return projs, nil
+}
+
+// minVersionFor finds the minimal version of the dependency's project that
+// is admitted by its constraint, and is not denied.
+func (s *solver) minVersionFor(dep dependency, memo map[string]Version) (Version, error) {
+	id, c := dep.dep.Ident, dep.dep.Constraint
+	key := string(id.ProjectRoot) + " " + id.Source + "@" + c.typedString()
+	if v, has := memo[key]; has {
+		return v, nil
+	}
+
+	vl, err := s.b.listVersions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bridge's list is shared, and may be sorted for upgrade.
+	svl := make([]Version, len(vl))
+	copy(svl, vl)
+	SortForDowngrade(svl)
+
+	fails := make([]failedVersion, 0, len(svl))
+	for _, v := range svl {
+		if !s.vUnify.matches(id, c, v) {
+			fails = append(fails, failedVersion{
+				v: v,
+				f: &versionNotAllowedFailure{
+					goal:       atom{id: id, v: v},
+					failparent: []dependency{dep},
+					c:          c,
+				},
+			})
+			continue
+		}
+		if err := s.checkAtomNotDenied(atom{id: id, v: v}); err != nil {
+			fails = append(fails, failedVersion{v: v, f: err})
+			continue
+		}
+		if err := s.checkAtomGoVersion(atom{id: id, v: v}); err != nil {
+			if _, ok := err.(*goVersionFailure); !ok {
+				return nil, err
+			}
+			fails = append(fails, failedVersion{v: v, f: err})
+			continue
+		}
+
+		memo[key] = v
+		return v, nil
+	}
+
+	// A revision need not correspond to any listed version.
+	if r, ok := c.(Revision); ok {
+		present, err := s.b.RevisionPresentIn(id, r)
+		if err != nil {
+			return nil, err
+		}
+		if !present {
+			return nil, &nonexistentRevisionFailure{goal: dep, r: r}
+		}
+		if err := s.checkAtomNotDenied(atom{id: id, v: r}); err != nil {
+			return nil, err
+		}
+		if err := s.checkAtomGoVersion(atom{id: id, v: r}); err != nil {
+			return nil, err
+		}
+		memo[key] = r
+		return r, nil
+	}
+
+	return nil, &noVersionError{pn: id, fails: fails}
+}
+
+type completeDepsByRoot []completeDep
+
+func (s completeDepsByRoot) Len() int {
+	return len(s)
+}
+
+func (s completeDepsByRoot) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s completeDepsByRoot) Less(i, j int) bool {
+	return s[i].Ident.ProjectRoot < s[j].Ident.ProjectRoot
+}
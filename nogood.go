@@ -0,0 +1,389 @@
+package gps
+
+import "sort"
+
+// A term is a statement about the current selection: that a particular
+// project is selected at a particular version, with at least a particular set
+// of its packages.
+type term struct {
+	id ProjectIdentifier
+	v  Version
+	pl []string // sorted
+}
+
+// A nogood is a set of terms that can never all hold at once in a solution.
+//
+// Nogoods are learned in the style of CDCL/PubGrub: directly from failed
+// satisfiability checks, and by resolving together the nogoods that
+// eliminated every version of a project. Because packages only ever add
+// dependencies, a nogood learned while a project had a given set of packages
+// remains valid when it has more.
+type nogood struct {
+	terms []term
+	// cause is the failure from which the nogood was learned.
+	cause error
+
+	// For derived nogoods, the project that was exhausted, each of its
+	// versions with the nogood that ruled it out, and the selections whose
+	// requirements made the project necessary.
+	exhausted  ProjectIdentifier
+	versions   []Version
+	from       []*nogood
+	requiredBy []atom
+}
+
+// nogoodStore indexes derived nogoods by the project roots of their terms.
+//
+// Nogoods learned directly from satisfiability checks are not kept here;
+// rediscovering them by repeating the check is just as cheap as looking them
+// up. Derived nogoods, on the other hand, can only otherwise be rediscovered
+// by searching the same subtree again.
+type nogoodStore struct {
+	byRoot map[ProjectRoot][]*nogood
+	count  int
+}
+
+func (ns *nogoodStore) add(ng *nogood) {
+	if ns.byRoot == nil {
+		ns.byRoot = make(map[ProjectRoot][]*nogood)
+	}
+
+	for _, t := range ng.terms {
+		ns.byRoot[t.id.ProjectRoot] = append(ns.byRoot[t.id.ProjectRoot], ng)
+	}
+	ns.count++
+}
+
+// termFor creates a term describing the current selection of the given
+// project, including all of its currently selected packages. If the project
+// is the root or is not selected, false is returned.
+func (s *solver) termFor(id ProjectIdentifier) (term, bool) {
+	if s.rd.isRoot(id.ProjectRoot) {
+		return term{}, false
+	}
+
+	awp, is := s.sel.selected(id)
+	if !is {
+		return term{}, false
+	}
+
+	pm := s.sel.getSelectedPackagesIn(awp.a.id)
+	pl := make([]string, 0, len(pm))
+	for pkg := range pm {
+		pl = append(pl, pkg)
+	}
+	sort.Strings(pl)
+
+	return term{id: awp.a.id, v: awp.a.v, pl: pl}, true
+}
+
+// holds indicates whether the term is satisfied by the current selection.
+func (s *solver) holds(t term) bool {
+	awp, is := s.sel.selected(t.id)
+	if !is || !awp.a.id.eq(t.id) || awp.a.v != t.v {
+		return false
+	}
+
+	pm := s.sel.getSelectedPackagesIn(t.id)
+	for _, pkg := range t.pl {
+		if _, has := pm[pkg]; !has {
+			return false
+		}
+	}
+	return true
+}
+
+// learn records a nogood from an error returned by a satisfiability check of
+// the given atom. If the error is not one from which anything can be learned,
+// nil is returned.
+func (s *solver) learn(a atomWithPackages, err error) *nogood {
+	pl := make([]string, len(a.pl))
+	copy(pl, a.pl)
+	sort.Strings(pl)
+	checkee := term{id: a.a.id, v: a.a.v, pl: pl}
+
+	ng := &nogood{cause: err}
+	add := func(id ProjectIdentifier) {
+		if t, has := s.termFor(id); has {
+			ng.terms = append(ng.terms, t)
+		}
+	}
+
+	switch e := err.(type) {
+	case *versionNotAllowedFailure:
+		// The checkee's packages have no bearing on whether its version is
+		// allowed.
+		ng.terms = append(ng.terms, term{id: a.a.id, v: a.a.v})
+		if len(e.failparent) > 0 {
+			add(e.failparent[0].depender.id)
+		} else {
+			// No single dependency rejected the atom, only their combination.
+			for _, dep := range s.sel.getDependenciesOn(a.a.id) {
+				add(dep.depender.id)
+			}
+		}
+	case *disjointConstraintFailure:
+		ng.terms = append(ng.terms, checkee)
+		if len(e.failsib) > 0 {
+			add(e.failsib[0].depender.id)
+		} else {
+			for _, dep := range e.nofailsib {
+				add(dep.depender.id)
+			}
+		}
+	case *constraintNotAllowedFailure:
+		ng.terms = append(ng.terms, checkee, term{id: e.goal.dep.Ident, v: e.v})
+	case *depHasProblemPackagesFailure:
+		ng.terms = append(ng.terms, checkee, term{id: e.goal.dep.Ident, v: e.v})
+	case *checkeeHasProblemPackagesFailure:
+		ng.terms = append(ng.terms, term{id: a.a.id, v: a.a.v})
+		for _, ed := range e.failpkg {
+			for _, depper := range ed.deppers {
+				add(depper.id)
+			}
+		}
+	case *nonexistentRevisionFailure:
+		ng.terms = append(ng.terms, checkee)
+	case *versionDeniedFailure, *goVersionFailure:
+		// A denied version, or one needing a newer Go, can never be selected,
+		// whatever else is selected.
+		ng.terms = append(ng.terms, term{id: a.a.id, v: a.a.v})
+	default:
+		// Source mismatches, and errors from the SourceManager, are not the
+		// kind of thing that can be learned.
+		return nil
+	}
+
+	ng.terms = dedupeTerms(ng.terms)
+	return ng
+}
+
+// resolve derives a nogood from an exhausted version queue by combining the
+// nogoods that eliminated each of its versions, then replacing the terms for
+// the queue's own project with selections that require it. If the reason any
+// version was eliminated is unknown, nil is returned.
+//
+// The derived nogood is recorded, so that the exhaustion can be anticipated
+// in other parts of the search.
+func (s *solver) resolve(q *versionQueue, err error) *nogood {
+	if len(q.reasons) != len(q.fails) || len(q.fails) == 0 {
+		return nil
+	}
+
+	ng := &nogood{
+		cause:     err,
+		exhausted: q.id,
+		from:      make([]*nogood, len(q.reasons)),
+	}
+	copy(ng.from, q.reasons)
+	for _, fv := range q.fails {
+		ng.versions = append(ng.versions, fv.v)
+	}
+
+	// The packages from the queue's project that were involved in the
+	// failures. Whatever requires the project must also require these, or
+	// the failures need not recur.
+	need := make(map[string]bool)
+	for _, r := range q.reasons {
+		if r == nil {
+			return nil
+		}
+
+		for _, t := range r.terms {
+			if t.id.eq(q.id) {
+				for _, pkg := range t.pl {
+					need[pkg] = true
+				}
+			} else {
+				ng.terms = append(ng.terms, t)
+			}
+		}
+	}
+
+	involved := func(id ProjectIdentifier) bool {
+		if s.rd.isRoot(id.ProjectRoot) {
+			return true
+		}
+		for _, t := range ng.terms {
+			if t.id.eq(id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Prefer dependers that are already involved, as they add no new
+	// projects to the nogood; then add others only as needed to require all
+	// the packages.
+	deps := s.sel.getDependenciesOn(q.id)
+	covered := make(map[string]bool)
+	var required bool
+	for _, pass := range []bool{true, false} {
+		for _, dep := range deps {
+			if involved(dep.depender.id) != pass {
+				continue
+			}
+
+			useful := !required
+			for _, pkg := range dep.dep.pl {
+				if need[pkg] && !covered[pkg] {
+					useful = true
+				}
+			}
+			if !useful {
+				continue
+			}
+
+			for _, pkg := range dep.dep.pl {
+				covered[pkg] = true
+			}
+			required = true
+			ng.requiredBy = append(ng.requiredBy, dep.depender)
+			// The term must include all the depender's packages, as those are
+			// what induce its dependency.
+			if t, has := s.termFor(dep.depender.id); has {
+				ng.terms = append(ng.terms, t)
+			}
+		}
+	}
+
+	for pkg := range need {
+		if !covered[pkg] {
+			required = false
+		}
+	}
+	if !required {
+		// Shouldn't be possible, but if so, nothing sound can be learned.
+		return nil
+	}
+
+	ng.terms = dedupeTerms(ng.terms)
+	s.ngs.add(ng)
+	return ng
+}
+
+// nogoodFor looks for a previously derived nogood that would be violated by
+// selecting the given atom, given the current selection.
+func (s *solver) nogoodFor(a atomWithPackages) *nogood {
+	var pm map[string]bool
+	for _, ng := range s.ngs.byRoot[a.a.id.ProjectRoot] {
+		var mine term
+		var found bool
+		for _, t := range ng.terms {
+			if t.id.eq(a.a.id) && t.v == a.a.v {
+				mine, found = t, true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		if pm == nil {
+			// Compare against the full set of packages that would be selected,
+			// including those internally reachable from the requested ones.
+			pl, _, err := s.getImportsAndConstraintsOf(a)
+			if err != nil {
+				return nil
+			}
+			pm = make(map[string]bool, len(pl))
+			for _, pkg := range pl {
+				pm[pkg] = true
+			}
+		}
+
+		violated := true
+		for _, pkg := range mine.pl {
+			if !pm[pkg] {
+				violated = false
+				break
+			}
+		}
+		for _, t := range ng.terms {
+			if !violated {
+				break
+			}
+			if !t.id.eq(a.a.id) {
+				violated = s.holds(t)
+			}
+		}
+
+		if violated {
+			return ng
+		}
+	}
+
+	return nil
+}
+
+// blame marks the version queues responsible for the nogood's terms as
+// failed, so that backtracking jumps directly to the most recent of them.
+func (s *solver) blame(ng *nogood) {
+	for _, t := range ng.terms {
+		// Some of a term's packages may have been added by later, package-only
+		// selections. Those were a consequence of some later project's
+		// selection, so blame the latest project selected before them.
+		last := -1
+		for k, sel := range s.sel.projects {
+			if sel.a.a.id.eq(t.id) && (sel.first || overlaps(sel.a.pl, t.pl)) {
+				last = k
+			}
+		}
+
+		// The root (at index 0) is never blamed.
+		for ; last > 0; last-- {
+			if sel := s.sel.projects[last]; sel.first {
+				s.fail(sel.a.a.id)
+				break
+			}
+		}
+	}
+}
+
+// dedupeTerms merges terms for the same project and version, combining their
+// packages.
+func dedupeTerms(terms []term) []term {
+	var out []term
+	for _, t := range terms {
+		var merged bool
+		for k, o := range out {
+			if o.id.eq(t.id) && o.v == t.v {
+				out[k].pl = mergePackages(o.pl, t.pl)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func mergePackages(a, b []string) []string {
+	m := make(map[string]bool, len(a)+len(b))
+	for _, pkg := range a {
+		m[pkg] = true
+	}
+	for _, pkg := range b {
+		m[pkg] = true
+	}
+
+	pl := make([]string, 0, len(m))
+	for pkg := range m {
+		pl = append(pl, pkg)
+	}
+	sort.Strings(pl)
+	return pl
+}
+
+func overlaps(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package gps
+
+import (
+	"log"
+	"testing"
+)
+
+func TestNogoodLearningBackjumps(t *testing.T) {
+	// c has nothing to do with the failure; every version of b rules out
+	// every version of x. Once that's learned, there's no point trying
+	// another version of c.
+	fix := basicFixture{
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "c *", "b *"),
+			mkDepspec("c 1.0.0", "x *"),
+			mkDepspec("c 2.0.0", "x *"),
+			mkDepspec("b 1.0.0", "x ^5.0.0"),
+			mkDepspec("b 2.0.0", "x ^5.0.0"),
+			mkDepspec("b 3.0.0", "x ^5.0.0"),
+			mkDepspec("x 1.0.0"),
+			mkDepspec("x 1.1.0"),
+			mkDepspec("x 1.2.0"),
+			mkDepspec("x 1.3.0"),
+		},
+	}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		Trace:           true,
+		TraceLogger:     log.New(testlogger{T: t}, "", 0),
+	}
+
+	is, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+	s := is.(*solver)
+
+	_, err = s.Solve()
+	uerr, ok := err.(*UnsolvableError)
+	if !ok {
+		t.Fatalf("expected an UnsolvableError, got %v", err)
+	}
+	if nve, ok := uerr.Err.(*noVersionError); !ok || nve.pn.ProjectRoot != "x" {
+		t.Fatalf("expected a noVersionError on x, got %v", uerr.Err)
+	}
+
+	// Each version of b took one attempt; c should never have been revisited.
+	if s.attempts > 2 {
+		t.Errorf("expected at most 2 attempts, got %v", s.attempts)
+	}
+
+	// Exhausting b, having already learned that each of its versions rules
+	// out x, proves there is no solution at all.
+	if s.conflict == nil || len(s.conflict.terms) != 0 {
+		t.Errorf("expected the final conflict to be an empty nogood, got %+v", s.conflict)
+	}
+
+	for _, ngs := range s.ngs.byRoot {
+		for _, ng := range ngs {
+			for _, tm := range ng.terms {
+				if tm.id.ProjectRoot == "c" {
+					t.Errorf("c should not appear in any learned nogood, but does in one caused by: %s", ng.cause)
+				}
+			}
+		}
+	}
+	if s.ngs.count < 4 {
+		t.Errorf("expected a nogood to be learned for each exhaustion of x and of b, got %v", s.ngs.count)
+	}
+}
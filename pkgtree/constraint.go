@@ -0,0 +1,372 @@
+package pkgtree
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// The GOOS and GOARCH values recognized in file names and build constraints,
+// as listed by go/build, including those no longer supported.
+var (
+	knownOS   = make(map[string]bool)
+	knownArch = make(map[string]bool)
+	unixOS    = make(map[string]bool)
+)
+
+// impliedOS maps each GOOS to another that it also satisfies, as go/build
+// does.
+var impliedOS = map[string]string{
+	"android": "linux",
+	"illumos": "solaris",
+	"ios":     "darwin",
+}
+
+func init() {
+	for _, os := range strings.Fields("aix android darwin dragonfly freebsd hurd illumos ios js linux nacl netbsd openbsd plan9 solaris wasip1 windows zos") {
+		knownOS[os] = true
+	}
+	for _, arch := range strings.Fields("386 amd64 amd64p32 arm armbe arm64 arm64be loong64 mips mipsle mips64 mips64le mips64p32 mips64p32le ppc ppc64 ppc64le riscv riscv64 s390 s390x sparc sparc64 wasm") {
+		knownArch[arch] = true
+	}
+	for _, os := range strings.Fields("aix android darwin dragonfly freebsd hurd illumos ios linux netbsd openbsd solaris") {
+		unixOS[os] = true
+	}
+}
+
+// A Target is a platform, and set of build tags, for which packages are to be
+// built. Both GOOS and GOARCH should be set.
+//
+// Targets are taken to be built as the go command would by default: release
+// tags of the form "go1.N" are always satisfied, as is "gc". So is "cgo",
+// unless NoCgo is set; set it for targets built with CGO_ENABLED=0, as
+// cross-compiled ones are by default.
+type Target struct {
+	GOOS, GOARCH string
+	Tags         []string
+	NoCgo        bool
+}
+
+// String renders the Target as GOOS/GOARCH, followed by any tags, and
+// "!cgo" if cgo is disabled.
+func (t Target) String() string {
+	tags := t.Tags
+	if t.NoCgo {
+		tags = append(tags[:len(tags):len(tags)], "!cgo")
+	}
+	if len(tags) == 0 {
+		return t.GOOS + "/" + t.GOARCH
+	}
+	return fmt.Sprintf("%s/%s (%s)", t.GOOS, t.GOARCH, strings.Join(tags, ","))
+}
+
+// satisfies indicates whether the build tag is set when building for the
+// target.
+func (t Target) satisfies(tag string) bool {
+	switch {
+	case tag == t.GOOS, tag == t.GOARCH:
+	case tag == impliedOS[t.GOOS]:
+	case tag == "unix" && unixOS[t.GOOS]:
+	case tag == "gc":
+	case tag == "cgo" && !t.NoCgo:
+	case strings.HasPrefix(tag, "go1."):
+	default:
+		for _, tt := range t.Tags {
+			if tt == tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// MatchesConstraint indicates whether a file subject to the build constraint,
+// given in //go:build syntax, is built for any of the targets. An empty
+// constraint matches everything, as does one that can't be parsed.
+func MatchesConstraint(cons string, targets []Target) bool {
+	if cons == "" {
+		return true
+	}
+	x, err := parseConstraint(cons)
+	if err != nil {
+		return true
+	}
+	for _, t := range targets {
+		if x.eval(t) {
+			return true
+		}
+	}
+	return false
+}
+
+type exprOp uint8
+
+const (
+	tagExpr exprOp = iota
+	notExpr
+	andExpr
+	orExpr
+)
+
+// expr is a parsed build constraint expression.
+type expr struct {
+	op   exprOp
+	tag  string
+	x, y *expr
+}
+
+func and(x, y *expr) *expr {
+	if x == nil {
+		return y
+	}
+	if y == nil {
+		return x
+	}
+	return &expr{op: andExpr, x: x, y: y}
+}
+
+func or(x, y *expr) *expr {
+	if x == nil {
+		return y
+	}
+	return &expr{op: orExpr, x: x, y: y}
+}
+
+func (x *expr) eval(t Target) bool {
+	switch x.op {
+	case tagExpr:
+		return t.satisfies(x.tag)
+	case notExpr:
+		return !x.x.eval(t)
+	case andExpr:
+		return x.x.eval(t) && x.y.eval(t)
+	default:
+		return x.x.eval(t) || x.y.eval(t)
+	}
+}
+
+// hasTag indicates whether the tag appears, un-negated, in the expression.
+func (x *expr) hasTag(tag string) bool {
+	switch x.op {
+	case tagExpr:
+		return x.tag == tag
+	case notExpr:
+		return false
+	default:
+		return x.x.hasTag(tag) || x.y.hasTag(tag)
+	}
+}
+
+// String renders the expression in //go:build syntax.
+func (x *expr) String() string {
+	switch x.op {
+	case tagExpr:
+		return x.tag
+	case notExpr:
+		if x.x.op == tagExpr {
+			return "!" + x.x.tag
+		}
+		return "!(" + x.x.String() + ")"
+	case andExpr:
+		return x.x.andOperand() + " && " + x.y.andOperand()
+	default:
+		return x.x.String() + " || " + x.y.String()
+	}
+}
+
+func (x *expr) andOperand() string {
+	if x.op == orExpr {
+		return "(" + x.String() + ")"
+	}
+	return x.String()
+}
+
+// parseConstraint parses a build constraint expression in //go:build syntax.
+func parseConstraint(s string) (*expr, error) {
+	p := &exprParser{s: s}
+	x := p.or()
+	if p.err == nil && p.next() != "" {
+		p.err = fmt.Errorf("unexpected %q in build constraint %q", p.tok, s)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return x, nil
+}
+
+type exprParser struct {
+	s   string
+	tok string
+	pos int
+	err error
+	// Whether tok was pushed back, to be returned by the next call to next.
+	back bool
+}
+
+func (p *exprParser) next() string {
+	if p.back {
+		p.back = false
+		return p.tok
+	}
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos == len(p.s) {
+		p.tok = ""
+		return p.tok
+	}
+
+	start := p.pos
+	switch c := p.s[p.pos]; {
+	case c == '&' || c == '|':
+		if p.pos+1 < len(p.s) && p.s[p.pos+1] == c {
+			p.pos += 2
+		} else {
+			p.pos++
+		}
+	case c == '!' || c == '(' || c == ')':
+		p.pos++
+	default:
+		for p.pos < len(p.s) && isTagChar(rune(p.s[p.pos])) {
+			p.pos++
+		}
+		if p.pos == start {
+			p.pos++
+		}
+	}
+	p.tok = p.s[start:p.pos]
+	return p.tok
+}
+
+func (p *exprParser) or() *expr {
+	x := p.and()
+	for p.err == nil && p.next() == "||" {
+		x = &expr{op: orExpr, x: x, y: p.and()}
+	}
+	p.back = true
+	return x
+}
+
+func (p *exprParser) and() *expr {
+	x := p.not()
+	for p.err == nil && p.next() == "&&" {
+		x = &expr{op: andExpr, x: x, y: p.not()}
+	}
+	p.back = true
+	return x
+}
+
+func (p *exprParser) not() *expr {
+	if p.err != nil {
+		return nil
+	}
+	switch tok := p.next(); {
+	case tok == "!":
+		return &expr{op: notExpr, x: p.not()}
+	case tok == "(":
+		x := p.or()
+		if p.err == nil && p.next() != ")" {
+			p.err = fmt.Errorf("missing close paren in build constraint %q", p.s)
+		}
+		return x
+	case isTag(tok):
+		return &expr{op: tagExpr, tag: tok}
+	default:
+		p.err = fmt.Errorf("unexpected %q in build constraint %q", tok, p.s)
+		return nil
+	}
+}
+
+func isTagChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+func isTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !isTagChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePlusBuild converts the options on a "// +build" line to an expression:
+// space-separated options are ORed, and comma-separated terms ANDed.
+func parsePlusBuild(line string) (*expr, error) {
+	var x *expr
+	for _, opt := range strings.Fields(line) {
+		var ox *expr
+		for _, term := range strings.Split(opt, ",") {
+			tx := &expr{op: tagExpr, tag: strings.TrimPrefix(term, "!")}
+			if !isTag(tx.tag) {
+				return nil, fmt.Errorf("invalid term %q in +build line %q", term, line)
+			}
+			if strings.HasPrefix(term, "!") {
+				tx = &expr{op: notExpr, x: tx}
+			}
+			ox = and(ox, tx)
+		}
+		x = or(x, ox)
+	}
+	return x, nil
+}
+
+// nameConstraint returns the constraint implied by the GOOS and GOARCH
+// suffixes of a file's name, if any, following the rules of go/build.
+func nameConstraint(name string) *expr {
+	name = strings.TrimSuffix(filepath.Base(name), ".go")
+	name = strings.TrimSuffix(name, "_test")
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return nil
+	}
+
+	l := strings.Split(name[i:], "_")
+	n := len(l)
+	if n >= 2 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+		return and(&expr{op: tagExpr, tag: l[n-2]}, &expr{op: tagExpr, tag: l[n-1]})
+	}
+	if n >= 1 && (knownOS[l[n-1]] || knownArch[l[n-1]]) {
+		return &expr{op: tagExpr, tag: l[n-1]}
+	}
+	return nil
+}
+
+// fileConstraint returns the build constraint on a parsed file, combining any
+// implied by its name with those in its //go:build line or, lacking one, its
+// "// +build" lines. Constraints that can't be parsed are disregarded, so that
+// the file's imports are never wrongly omitted.
+func fileConstraint(name string, pf *ast.File) *expr {
+	var gobuild, plus *expr
+	var sawGoBuild bool
+	for _, c := range pf.Comments {
+		if c.Pos() > pf.Package { // build constraints must come before package
+			continue
+		}
+
+		for _, cl := range c.List {
+			switch {
+			case strings.HasPrefix(cl.Text, "//go:build "):
+				sawGoBuild = true
+				if x, err := parseConstraint(strings.TrimSpace(cl.Text[len("//go:build "):])); err == nil {
+					gobuild = x
+				}
+			case strings.HasPrefix(cl.Text, "// +build "):
+				if x, err := parsePlusBuild(cl.Text[len("// +build "):]); err == nil {
+					plus = and(plus, x)
+				}
+			}
+		}
+	}
+
+	if sawGoBuild {
+		return and(nameConstraint(name), gobuild)
+	}
+	return and(nameConstraint(name), plus)
+}
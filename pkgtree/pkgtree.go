@@ -11,7 +11,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"unicode"
 )
 
 // Package represents a Go package. It contains a subset of the information
@@ -22,6 +21,59 @@ type Package struct {
 	CommentPath string   // Import path given in the comment on the package statement
 	Imports     []string // Imports from all go and cgo files
 	TestImports []string // Imports from all go test files (in go/build parlance: both TestImports and XTestImports)
+
+	// ConstrainedImports holds those of the Imports that appear only in files
+	// subject to build constraints, keyed by the constraint, in //go:build
+	// syntax. Nil if there are none. Files tagged "ignore" are not taken to be
+	// constrained, so that their imports are always followed.
+	ConstrainedImports map[string][]string
+	// ConstrainedTestImports is as ConstrainedImports, for the TestImports.
+	ConstrainedTestImports map[string][]string
+}
+
+// ImportsFor returns the package's imports that are reachable when building for
+// any of the targets, including those from test files if tests is true. If no
+// targets are given, all the imports are returned.
+func (p Package) ImportsFor(targets []Target, tests bool) []string {
+	if len(targets) == 0 {
+		if tests {
+			return dedupeStrings(p.Imports, p.TestImports)
+		}
+		return p.Imports
+	}
+
+	imps := filterImports(p.Imports, p.ConstrainedImports, targets)
+	if tests {
+		imps = dedupeStrings(imps, filterImports(p.TestImports, p.ConstrainedTestImports, targets))
+	}
+	return imps
+}
+
+// filterImports drops those imports that only appear under constraints that
+// none of the targets satisfy.
+func filterImports(imps []string, cons map[string][]string, targets []Target) []string {
+	if len(cons) == 0 {
+		return imps
+	}
+
+	// An import may appear under several constraints; it's kept if any of
+	// them is satisfied.
+	cond, sat := make(map[string]bool), make(map[string]bool)
+	for c, cimps := range cons {
+		ok := MatchesConstraint(c, targets)
+		for _, imp := range cimps {
+			cond[imp] = true
+			sat[imp] = sat[imp] || ok
+		}
+	}
+
+	out := make([]string, 0, len(imps))
+	for _, imp := range imps {
+		if !cond[imp] || sat[imp] {
+			out = append(out, imp)
+		}
+	}
+	return out
 }
 
 // ListPackages reports Go package information about all directories in the tree
@@ -111,16 +163,19 @@ func ListPackages(fileRoot, importRoot string) (PackageTree, error) {
 		p := &build.Package{
 			Dir: wp,
 		}
-		err = fillPackage(p)
+		var cimps, ctimps map[string][]string
+		cimps, ctimps, err = fillPackage(p)
 
 		var pkg Package
 		if err == nil {
 			pkg = Package{
-				ImportPath:  ip,
-				CommentPath: p.ImportComment,
-				Name:        p.Name,
-				Imports:     p.Imports,
-				TestImports: dedupeStrings(p.TestImports, p.XTestImports),
+				ImportPath:             ip,
+				CommentPath:            p.ImportComment,
+				Name:                   p.Name,
+				Imports:                p.Imports,
+				TestImports:            dedupeStrings(p.TestImports, p.XTestImports),
+				ConstrainedImports:     cimps,
+				ConstrainedTestImports: ctimps,
 			}
 		} else {
 			switch err.(type) {
@@ -176,24 +231,26 @@ func ListPackages(fileRoot, importRoot string) (PackageTree, error) {
 	return ptree, nil
 }
 
-// fillPackage full of info. Assumes p.Dir is set at a minimum
-func fillPackage(p *build.Package) error {
-	var buildPrefix = "// +build "
-	var buildFieldSplit = func(r rune) bool {
-		return unicode.IsSpace(r) || r == ','
-	}
-
+// fillPackage full of info. Assumes p.Dir is set at a minimum.
+//
+// Imports are gathered from all files, whatever their build constraints. Those
+// that appear only in constrained files are also returned, keyed by constraint.
+func fillPackage(p *build.Package) (cimps, ctimps map[string][]string, err error) {
 	gofiles, err := filepath.Glob(filepath.Join(p.Dir, "*.go"))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	if len(gofiles) == 0 {
-		return &build.NoGoError{Dir: p.Dir}
+		return nil, nil, &build.NoGoError{Dir: p.Dir}
 	}
 
 	var testImports []string
 	var imports []string
+	// Imports from files without constraints, and those from files with them,
+	// by constraint.
+	plain, tplain := make(map[string]bool), make(map[string]bool)
+	cons, tcons := make(map[string][]string), make(map[string][]string)
 	for _, file := range gofiles {
 		// Skip underscore-led files, in keeping with the rest of the toolchain.
 		if filepath.Base(file)[0] == '_' {
@@ -204,34 +261,21 @@ func fillPackage(p *build.Package) error {
 			if os.IsPermission(err) {
 				continue
 			}
-			return err
+			return nil, nil, err
 		}
 		testFile := strings.HasSuffix(file, "_test.go")
 		fname := filepath.Base(file)
 
+		// hardcoded (for now) handling for the "ignore" build tag
+		// We "soft" ignore the files tagged with ignore so that we pull in their
+		// imports. Their imports are taken as unconstrained, as no target could
+		// ever satisfy the constraint.
+		var c string
 		var ignored bool
-		for _, c := range pf.Comments {
-			if c.Pos() > pf.Package { // +build comment must come before package
-				continue
-			}
-
-			var ct string
-			for _, cl := range c.List {
-				if strings.HasPrefix(cl.Text, buildPrefix) {
-					ct = cl.Text
-					break
-				}
-			}
-			if ct == "" {
-				continue
-			}
-
-			for _, t := range strings.FieldsFunc(ct[len(buildPrefix):], buildFieldSplit) {
-				// hardcoded (for now) handling for the "ignore" build tag
-				// We "soft" ignore the files tagged with ignore so that we pull in their imports.
-				if t == "ignore" {
-					ignored = true
-				}
+		if x := fileConstraint(fname, pf); x != nil {
+			ignored = x.hasTag("ignore")
+			if !ignored {
+				c = x.String()
 			}
 		}
 
@@ -250,7 +294,17 @@ func fillPackage(p *build.Package) error {
 		for _, is := range pf.Imports {
 			name, err := strconv.Unquote(is.Path.Value)
 			if err != nil {
-				return err // can't happen?
+				return nil, nil, err // can't happen?
+			}
+			switch {
+			case testFile && c == "":
+				tplain[name] = true
+			case testFile:
+				tcons[c] = append(tcons[c], name)
+			case c == "":
+				plain[name] = true
+			default:
+				cons[c] = append(cons[c], name)
 			}
 			if testFile {
 				testImports = append(testImports, name)
@@ -264,7 +318,29 @@ func fillPackage(p *build.Package) error {
 	testImports = uniq(testImports)
 	p.Imports = imports
 	p.TestImports = testImports
-	return nil
+	return onlyConstrained(cons, plain), onlyConstrained(tcons, tplain), nil
+}
+
+// onlyConstrained dedupes the imports under each constraint, dropping those
+// that also appear in unconstrained files. Nil is returned if nothing remains.
+func onlyConstrained(cons map[string][]string, plain map[string]bool) map[string][]string {
+	var out map[string][]string
+	for c, imps := range cons {
+		var keep []string
+		for _, imp := range uniq(imps) {
+			if !plain[imp] {
+				keep = append(keep, imp)
+			}
+		}
+		if len(keep) == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string][]string)
+		}
+		out[c] = keep
+	}
+	return out
 }
 
 // LocalImportsError indicates that a package contains at least one relative
@@ -417,7 +493,19 @@ type PackageTree struct {
 // 	"A": []string{},
 // 	"A/bar": []string{"B/baz"},
 //  }
+//
+// Standard library imports are included in the returned map. Use
+// ReachMap.FlattenFor() to exclude those of a particular Go release.
 func (t PackageTree) ToReachMap(main, tests, backprop bool, ignore map[string]bool) (ReachMap, map[string]*ProblemImportError) {
+	return t.ToReachMapFor(main, tests, backprop, ignore, nil)
+}
+
+// ToReachMapFor is as ToReachMap, but only follows imports that are reachable
+// when building for at least one of the targets, disregarding those that
+// appear only in files whose build constraints none of the targets satisfy.
+//
+// If no targets are given, all imports are followed, as with ToReachMap.
+func (t PackageTree) ToReachMapFor(main, tests, backprop bool, ignore map[string]bool, targets []Target) (ReachMap, map[string]*ProblemImportError) {
 	if ignore == nil {
 		ignore = make(map[string]bool)
 	}
@@ -425,7 +513,6 @@ func (t PackageTree) ToReachMap(main, tests, backprop bool, ignore map[string]bo
 	// world's simplest adjacency list
 	workmap := make(map[string]wm)
 
-	var imps []string
 	for ip, perr := range t.Packages {
 		if perr.Err != nil {
 			workmap[ip] = wm{
@@ -444,12 +531,7 @@ func (t PackageTree) ToReachMap(main, tests, backprop bool, ignore map[string]bo
 			continue
 		}
 
-		imps = imps[:0]
-		if tests {
-			imps = dedupeStrings(p.Imports, p.TestImports)
-		} else {
-			imps = p.Imports
-		}
+		imps := p.ImportsFor(targets, tests)
 
 		w := wm{
 			ex: make(map[string]bool),
@@ -499,6 +581,8 @@ func (t PackageTree) Copy() PackageTree {
 			poe2.P.TestImports = make([]string, len(poe.P.TestImports))
 			copy(poe2.P.TestImports, poe.P.TestImports)
 		}
+		poe2.P.ConstrainedImports = copyConstrained(poe.P.ConstrainedImports)
+		poe2.P.ConstrainedTestImports = copyConstrained(poe.P.ConstrainedTestImports)
 
 		t2.Packages[path] = poe2
 	}
@@ -506,6 +590,19 @@ func (t PackageTree) Copy() PackageTree {
 	return t2
 }
 
+func copyConstrained(cons map[string][]string) map[string][]string {
+	if cons == nil {
+		return nil
+	}
+
+	cons2 := make(map[string][]string, len(cons))
+	for c, imps := range cons {
+		cons2[c] = make([]string, len(imps))
+		copy(cons2[c], imps)
+	}
+	return cons2
+}
+
 // wmToReach takes an internal "workmap" constructed by
 // PackageTree.ExternalReach(), transitively walks (via depth-first traversal)
 // all internal imports until they reach an external path or terminate, then
@@ -27,7 +27,7 @@ func init() {
 // sets the IsStdLib func to always return false, otherwise it would identify
 // pretty much all of our fixtures as being stdlib and skip everything.
 func overrideIsStdLib() {
-	internal.IsStdLib = func(path string) bool {
+	internal.IsStdLib = func(path, goVersion string) bool {
 		return false
 	}
 }
@@ -1276,6 +1276,46 @@ func TestListPackages(t *testing.T) {
 				},
 			},
 		},
+		"imports under build constraints": {
+			fileRoot:   j("buildcons"),
+			importRoot: "buildcons",
+			out: PackageTree{
+				ImportRoot: "buildcons",
+				Packages: map[string]PackageOrErr{
+					"buildcons": {
+						P: Package{
+							ImportPath:  "buildcons",
+							CommentPath: "",
+							Name:        "buildcons",
+							Imports: []string{
+								"github.com/arm/neon",
+								"github.com/gc/asm",
+								"github.com/riscv/vector",
+								"github.com/solaris/doors",
+								"golang.org/x/sys/windows",
+								"google.golang.org/appengine",
+								"sort",
+							},
+							TestImports: []string{
+								"github.com/integration/harness",
+							},
+							ConstrainedImports: map[string][]string{
+								"windows":                               {"golang.org/x/sys/windows"},
+								"linux && arm64":                        {"github.com/arm/neon"},
+								"linux && riscv64":                      {"github.com/riscv/vector"},
+								"appengine || linux && !cgo":            {"google.golang.org/appengine"},
+								"(darwin && amd64 || windows) && !nacl": {"golang.org/x/sys/windows"},
+								"solaris":                               {"github.com/solaris/doors"},
+								"gc":                                    {"github.com/gc/asm"},
+							},
+							ConstrainedTestImports: map[string][]string{
+								"integration": {"github.com/integration/harness"},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for name, fix := range table {
@@ -1862,6 +1902,120 @@ func TestFlattenReachMap(t *testing.T) {
 	}
 }
 
+func TestFlattenForGoVersion(t *testing.T) {
+	internal.IsStdLib = doIsStdLib
+	defer overrideIsStdLib()
+
+	rm := ReachMap{
+		"root":          {External: []string{"context", "github.com/foo/bar", "sort"}},
+		"root/testdata": {External: []string{"github.com/baz/qux"}},
+	}
+
+	table := map[string]struct {
+		all   bool
+		gover string
+		out   []string
+	}{
+		"newest":     {out: []string{"github.com/foo/bar"}},
+		"go1.6":      {gover: "go1.6", out: []string{"context", "github.com/foo/bar"}},
+		"go1.7":      {gover: "go1.7", out: []string{"github.com/foo/bar"}},
+		"heuristic":  {gover: StdLibHeuristic, out: []string{"github.com/foo/bar"}},
+		"all, go1.6": {all: true, gover: "go1.6", out: []string{"context", "github.com/baz/qux", "github.com/foo/bar"}},
+	}
+
+	for name, fix := range table {
+		got := rm.FlattenFor(fix.gover)
+		if fix.all {
+			got = rm.FlattenAllFor(fix.gover)
+		}
+		if !reflect.DeepEqual(fix.out, got) {
+			t.Errorf("(%s) wrong imports:\n\t(GOT): %s\n\t(WNT): %s", name, got, fix.out)
+		}
+	}
+}
+
+func TestToReachMapFor(t *testing.T) {
+	ptree, err := ListPackages(filepath.Join(getTestdataRootDir(t), "src", "buildcons"), "buildcons")
+	if err != nil {
+		t.Fatalf("ListPackages failed on buildcons test case: %s", err)
+	}
+
+	table := map[string]struct {
+		targets []Target
+		tests   bool
+		out     []string
+	}{
+		"no targets": {
+			tests: true,
+			out: []string{
+				"github.com/arm/neon",
+				"github.com/gc/asm",
+				"github.com/integration/harness",
+				"github.com/riscv/vector",
+				"github.com/solaris/doors",
+				"golang.org/x/sys/windows",
+				"google.golang.org/appengine",
+				"sort",
+			},
+		},
+		"linux": {
+			targets: []Target{{GOOS: "linux", GOARCH: "amd64"}, {GOOS: "linux", GOARCH: "arm64"}},
+			out: []string{
+				"github.com/arm/neon",
+				"github.com/gc/asm",
+				"sort",
+			},
+		},
+		"linux without cgo": {
+			targets: []Target{{GOOS: "linux", GOARCH: "amd64", NoCgo: true}},
+			tests:   true,
+			out:     []string{"github.com/gc/asm", "google.golang.org/appengine", "sort"},
+		},
+		"linux on riscv64": {
+			targets: []Target{{GOOS: "linux", GOARCH: "riscv64"}},
+			out:     []string{"github.com/gc/asm", "github.com/riscv/vector", "sort"},
+		},
+		"windows": {
+			targets: []Target{{GOOS: "windows", GOARCH: "386"}},
+			out:     []string{"github.com/gc/asm", "golang.org/x/sys/windows", "sort"},
+		},
+		"darwin with tests and tags": {
+			targets: []Target{{GOOS: "darwin", GOARCH: "amd64", Tags: []string{"integration"}}},
+			tests:   true,
+			out: []string{
+				"github.com/gc/asm",
+				"github.com/integration/harness",
+				"golang.org/x/sys/windows",
+				"sort",
+			},
+		},
+		"ios implies darwin": {
+			targets: []Target{{GOOS: "ios", GOARCH: "amd64"}},
+			out:     []string{"github.com/gc/asm", "golang.org/x/sys/windows", "sort"},
+		},
+		"illumos implies solaris": {
+			targets: []Target{{GOOS: "illumos", GOARCH: "amd64"}},
+			out:     []string{"github.com/gc/asm", "github.com/solaris/doors", "sort"},
+		},
+		"nacl": {
+			targets: []Target{{GOOS: "nacl", GOARCH: "amd64p32", Tags: []string{"appengine"}}},
+			out:     []string{"github.com/gc/asm", "google.golang.org/appengine", "sort"},
+		},
+	}
+
+	for name, fix := range table {
+		t.Run(name, func(t *testing.T) {
+			rm, em := ptree.ToReachMapFor(true, fix.tests, true, nil, fix.targets)
+			if len(em) != 0 {
+				t.Errorf("Should not have any error packages from ToReachMapFor, got %s", em)
+			}
+			if got := rm.FlattenAll(true); !reflect.DeepEqual(fix.out, got) {
+				t.Errorf("Wrong imports:\n\t(GOT): %s\n\t(WNT): %s", got, fix.out)
+			}
+		})
+	}
+}
+
 // Verify that we handle import cycles correctly - drop em all
 func TestToReachMapCycle(t *testing.T) {
 	ptree, err := ListPackages(filepath.Join(getTestdataRootDir(t), "src", "cycle"), "cycle")
@@ -16,12 +16,40 @@ type ReachMap map[string]struct {
 	Internal, External []string
 }
 
+// StdLibHeuristic, given in place of a Go version, selects the heuristic that
+// treats any import path whose first element lacks a dot as being in the
+// standard library, rather than the packages of a particular Go release.
+const StdLibHeuristic = internal.StdLibHeuristic
+
+// IsStdLib reports whether the import path is of a package in the standard
+// library of the given Go release, such as "go1.9". If the release is empty,
+// StdLibHeuristic is used.
+func IsStdLib(path, goVersion string) bool {
+	return internal.IsStdLib(path, goVersion)
+}
+
+// ParseGoVersion normalizes a Go release, such as "1.9", "go1.9" or "go1.9.2",
+// to the form "go1.9", returning an error if its standard library is not
+// known. The empty string is taken to mean StdLibHeuristic, which is
+// returned as is.
+func ParseGoVersion(v string) (string, error) {
+	return internal.ParseGoVersion(v)
+}
+
 // FlattenAll flattens a reachmap into a sorted, deduplicated list of all the
 // external imports named by its contained packages.
 //
-// If stdlib is false, then stdlib imports are excluded from the result.
+// If stdlib is false, then imports that StdLibHeuristic deems to be of
+// standard library packages are excluded from the result.
 func (rm ReachMap) FlattenAll(stdlib bool) []string {
-	return rm.flatten(func(pkg string) bool { return true }, stdlib)
+	return rm.flatten(func(pkg string) bool { return true }, stdlib, "")
+}
+
+// FlattenAllFor is the same as FlattenAll(false), but excludes imports of
+// packages in the standard library of the given Go release, as accepted by
+// ParseGoVersion, rather than by StdLibHeuristic.
+func (rm ReachMap) FlattenAllFor(goVersion string) []string {
+	return rm.flatten(func(pkg string) bool { return true }, false, goVersion)
 }
 
 // Flatten flattens a reachmap into a sorted, deduplicated list of all the
@@ -29,31 +57,39 @@ func (rm ReachMap) FlattenAll(stdlib bool) []string {
 // from packages with disallowed patterns in their names: any path element with
 // a leading dot, a leading underscore, with the name "testdata".
 //
-// If stdlib is false, then stdlib imports are excluded from the result.
+// If stdlib is false, then imports that StdLibHeuristic deems to be of
+// standard library packages are excluded from the result.
 func (rm ReachMap) Flatten(stdlib bool) []string {
-	f := func(pkg string) bool {
-		// Eliminate import paths with any elements having leading dots, leading
-		// underscores, or testdata. If these are internally reachable (which is
-		// a no-no, but possible), any external imports will have already been
-		// pulled up through ExternalReach. The key here is that we don't want
-		// to treat such packages as themselves being sources.
-		for _, elem := range strings.Split(pkg, "/") {
-			if strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_") || elem == "testdata" {
-				return false
-			}
+	return rm.flatten(isSourcePkg, stdlib, "")
+}
+
+// FlattenFor is the same as Flatten(false), but excludes imports of packages
+// in the standard library of the given Go release, as accepted by
+// ParseGoVersion, rather than by StdLibHeuristic.
+func (rm ReachMap) FlattenFor(goVersion string) []string {
+	return rm.flatten(isSourcePkg, false, goVersion)
+}
+
+// isSourcePkg eliminates import paths with any elements having leading dots,
+// leading underscores, or testdata. If these are internally reachable (which
+// is a no-no, but possible), any external imports will have already been
+// pulled up through ExternalReach. The key here is that we don't want to treat
+// such packages as themselves being sources.
+func isSourcePkg(pkg string) bool {
+	for _, elem := range strings.Split(pkg, "/") {
+		if strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_") || elem == "testdata" {
+			return false
 		}
-		return true
 	}
-
-	return rm.flatten(f, stdlib)
+	return true
 }
 
-func (rm ReachMap) flatten(filter func(string) bool, stdlib bool) []string {
+func (rm ReachMap) flatten(filter func(string) bool, stdlib bool, goVersion string) []string {
 	exm := make(map[string]struct{})
 	for pkg, ie := range rm {
 		if filter(pkg) {
 			for _, ex := range ie.External {
-				if !stdlib && internal.IsStdLib(ex) {
+				if !stdlib && internal.IsStdLib(ex, goVersion) {
 					continue
 				}
 				exm[ex] = struct{}{}
@@ -72,4 +108,4 @@ func (rm ReachMap) flatten(filter func(string) bool, stdlib bool) []string {
 
 	sort.Strings(ex)
 	return ex
-}
\ No newline at end of file
+}
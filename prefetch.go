@@ -0,0 +1,212 @@
+package gps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PrefetchProgress describes the outcome of prefetching a single project. It
+// is passed to the callback provided to SourceMgr.PrefetchLock() and
+// SourceMgr.PrefetchSources().
+type PrefetchProgress struct {
+	// ID is the project that was prefetched.
+	ID ProjectIdentifier
+	// Revision is the revision that was required to be present in the local
+	// cache, or the empty string if none was.
+	Revision Revision
+	// Err is nil if the project was successfully prefetched.
+	Err error
+	// Completed is the number of projects prefetched so far, including this
+	// one, whether successful or not.
+	Completed int
+	// Total is the total number of projects being prefetched.
+	Total int
+}
+
+// PrefetchError is returned from a prefetch if one or more projects could not
+// be prefetched.
+type PrefetchError struct {
+	// Failures maps each project that failed to the error it encountered.
+	Failures map[ProjectIdentifier]error
+}
+
+func (e *PrefetchError) Error() string {
+	ids := make([]ProjectIdentifier, 0, len(e.Failures))
+	for id := range e.Failures {
+		ids = append(ids, id)
+	}
+	sort.Sort(pisorter(ids))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "failed to prefetch %v project(s):", len(ids))
+	for _, id := range ids {
+		fmt.Fprintf(&buf, "\n\t%s: %s", id.errString(), e.Failures[id])
+	}
+	return buf.String()
+}
+
+// PrefetchLock concurrently ensures that the sources for all the projects in
+// the provided Lock exist in the local cache, are up to date, and contain
+// the locked revisions. It is intended to allow tools to warm the cache ahead
+// of time - for example, in a separate CI stage.
+//
+// If non-nil, the progress func is called once for each project as it
+// completes. Calls are never made concurrently, so the func need not be
+// threadsafe.
+//
+// If any project fails, a *PrefetchError is returned describing all the
+// failures. Concurrency is bounded by the SourceMgr's ConcurrencyLimits.
+func (sm *SourceMgr) PrefetchLock(ctx context.Context, l Lock, progress func(PrefetchProgress)) error {
+	lps := l.Projects()
+	targets := make([]prefetchTarget, len(lps))
+	for k, lp := range lps {
+		targets[k] = prefetchTarget{id: lp.Ident(), r: lp.r}
+	}
+
+	return prefetch(ctx, sm, targets, progress)
+}
+
+// PrefetchSources is the same as PrefetchLock, but only ensures that the
+// sources for each of the provided ProjectIdentifiers exist in the local
+// cache and are up to date.
+func (sm *SourceMgr) PrefetchSources(ctx context.Context, ids []ProjectIdentifier, progress func(PrefetchProgress)) error {
+	targets := make([]prefetchTarget, len(ids))
+	for k, id := range ids {
+		targets[k] = prefetchTarget{id: id}
+	}
+
+	return prefetch(ctx, sm, targets, progress)
+}
+
+type pisorter []ProjectIdentifier
+
+func (s pisorter) Len() int           { return len(s) }
+func (s pisorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s pisorter) Less(i, j int) bool { return s[i].less(s[j]) }
+
+type prefetchTarget struct {
+	id ProjectIdentifier
+	r  Revision
+}
+
+func prefetch(ctx context.Context, sm ContextSourceManager, targets []prefetchTarget, progress func(PrefetchProgress)) error {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex // guards fails, completed, and calls to progress
+		fails     = make(map[ProjectIdentifier]error)
+		completed int
+	)
+
+	wg.Add(len(targets))
+	for _, t := range targets {
+		go func(t prefetchTarget) {
+			defer wg.Done()
+			err := prefetchOne(ctx, sm, t)
+
+			mu.Lock()
+			defer mu.Unlock()
+			completed++
+			if err != nil {
+				fails[t.id] = err
+			}
+			if progress != nil {
+				progress(PrefetchProgress{
+					ID:        t.id,
+					Revision:  t.r,
+					Err:       err,
+					Completed: completed,
+					Total:     len(targets),
+				})
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	if len(fails) > 0 {
+		return &PrefetchError{Failures: fails}
+	}
+	return nil
+}
+
+func prefetchOne(ctx context.Context, sm ContextSourceManager, t prefetchTarget) error {
+	if err := sm.SyncSourceForContext(ctx, t.id); err != nil {
+		return err
+	}
+
+	if t.r == "" {
+		return nil
+	}
+
+	has, err := sm.RevisionPresentInContext(ctx, t.id, t.r)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return fmt.Errorf("revision %s is not present in %s, even after syncing", t.r, t.id.errString())
+	}
+	return nil
+}
+
+// DefaultPrefetchConcurrency is the number of speculative requests a solver
+// makes of its SourceManager at once, unless
+// SolveParameters.PrefetchConcurrency says otherwise.
+const DefaultPrefetchConcurrency = 8
+
+// prefetcher runs speculative fetches for a solver in the background, with
+// bounded concurrency. Each fetch is only made once.
+type prefetcher struct {
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	mu   sync.Mutex // guards seen
+	seen map[string]bool
+}
+
+func newPrefetcher(n int) *prefetcher {
+	return &prefetcher{
+		sem:  make(chan struct{}, n),
+		seen: make(map[string]bool),
+	}
+}
+
+// claim records that the named fetch for the project, and version if
+// non-nil, is to be made. It returns false if it already has been.
+func (p *prefetcher) claim(kind string, id ProjectIdentifier, v Version) bool {
+	key := kind + " " + string(id.ProjectRoot) + " " + id.Source
+	if v != nil {
+		key += "@" + v.typedString()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seen[key] {
+		return false
+	}
+	p.seen[key] = true
+	return true
+}
+
+// do runs f in the background once there's capacity, unless ctx is done
+// first.
+func (p *prefetcher) do(ctx context.Context, f func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-p.sem }()
+
+		if ctx.Err() == nil {
+			f()
+		}
+	}()
+}
+
+func (p *prefetcher) wait() {
+	p.wg.Wait()
+}
@@ -0,0 +1,271 @@
+package gps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sdboyer/gps/pkgtree"
+)
+
+// revSM is a fixture SourceManager that knows about a fixed set of revisions
+// for each project.
+type revSM struct {
+	*depspecSourceManager
+	revs map[ProjectRoot][]Revision
+}
+
+func (sm *revSM) RevisionPresentInContext(ctx context.Context, id ProjectIdentifier, r Revision) (bool, error) {
+	for _, rev := range sm.revs[id.ProjectRoot] {
+		if rev == r {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestPrefetch(t *testing.T) {
+	sm := &revSM{
+		depspecSourceManager: newdepspecSM([]depspec{
+			mkDepspec("root 0.0.0"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("bar 1.0.0"),
+		}, nil),
+		revs: map[ProjectRoot][]Revision{
+			"foo": {"foorev"},
+			"bar": {"barrev"},
+		},
+	}
+
+	targets := []prefetchTarget{
+		{id: mkPI("foo"), r: "foorev"},
+		{id: mkPI("bar"), r: "nonexistent"},
+		{id: mkPI("baz")},
+		{id: mkPI("bar")},
+	}
+
+	seen := make(map[int]bool)
+	fails := make(map[ProjectRoot]bool)
+	err := prefetch(context.Background(), sm, targets, func(p PrefetchProgress) {
+		if p.Total != len(targets) {
+			t.Errorf("expected total of %v, got %v", len(targets), p.Total)
+		}
+		if seen[p.Completed] {
+			t.Errorf("completed count %v was reported more than once", p.Completed)
+		}
+		seen[p.Completed] = true
+		if p.Err != nil {
+			fails[p.ID.ProjectRoot] = true
+		}
+	})
+
+	if len(seen) != len(targets) {
+		t.Errorf("expected %v progress reports, got %v", len(targets), len(seen))
+	}
+
+	perr, ok := err.(*PrefetchError)
+	if !ok {
+		t.Fatalf("expected *PrefetchError, got %T: %v", err, err)
+	}
+
+	for _, pr := range []ProjectRoot{"bar", "baz"} {
+		if !fails[pr] {
+			t.Errorf("expected a failure to be reported for %s", pr)
+		}
+		if _, has := perr.Failures[mkPI(string(pr))]; !has {
+			t.Errorf("expected %s to be recorded in PrefetchError", pr)
+		}
+	}
+	if fails["foo"] {
+		t.Error("foo should have been prefetched successfully")
+	}
+}
+
+func TestPrefetchLock(t *testing.T) {
+	// This test is a bit slow, skip it on -short
+	if testing.Short() {
+		t.Skip("Skipping prefetch test in short mode")
+	}
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	id := mkPI("github.com/sdboyer/gpkt").normalize()
+	l := SimpleLock{
+		NewLockedProject(id, NewVersion("v1.0.0").Is(Revision("bf85021c0405edbc4f3648b0603818d641674f72")), nil),
+	}
+
+	var reports []PrefetchProgress
+	err := sm.PrefetchLock(context.Background(), l, func(p PrefetchProgress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error on prefetch: %s", err)
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("expected one progress report, got %v", len(reports))
+	}
+	if reports[0].ID != id || reports[0].Err != nil {
+		t.Errorf("unexpected progress report: %+v", reports[0])
+	}
+
+	lpath := filepath.Join(sm.cachedir, "sources", "https---github.com-sdboyer-gpkt")
+	if _, err = os.Stat(lpath); err != nil {
+		t.Errorf("source should exist in the local cache after prefetching: %s", err)
+	}
+}
+
+// countingSM is a fixture SourceManager that records how many package tree
+// requests it is serving at once. The fixture bridge lists packages without
+// going through the context-taking method, so only speculative requests are
+// counted.
+type countingSM struct {
+	*depspecSourceManager
+	mu             sync.Mutex
+	calls, running int
+	maxRunning     int
+}
+
+func (sm *countingSM) ListPackagesContext(ctx context.Context, id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	sm.mu.Lock()
+	sm.calls++
+	sm.running++
+	if sm.running > sm.maxRunning {
+		sm.maxRunning = sm.running
+	}
+	sm.mu.Unlock()
+
+	// Linger, so that overlapping requests are seen.
+	time.Sleep(5 * time.Millisecond)
+
+	sm.mu.Lock()
+	sm.running--
+	sm.mu.Unlock()
+	return sm.depspecSourceManager.ListPackagesContext(ctx, id, v)
+}
+
+// Manifests are slow to fetch, like they would be over the network, giving
+// speculative requests a chance to run before the solve is over.
+func (sm *countingSM) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	time.Sleep(5 * time.Millisecond)
+	return sm.depspecSourceManager.GetManifestAndLockContext(ctx, id, v, an)
+}
+
+func TestSolvePrefetch(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	solve := func(n int) ([]LockedProject, *countingSM) {
+		sm := &countingSM{depspecSourceManager: newdepspecSM(fix.ds, nil)}
+		params := SolveParameters{
+			RootDir:             string(fix.ds[0].n),
+			RootPackageTree:     fix.rootTree(),
+			Manifest:            fix.rootmanifest(),
+			ProjectAnalyzer:     naiveAnalyzer{},
+			PrefetchConcurrency: n,
+		}
+
+		s, err := Prepare(params, sm)
+		if err != nil {
+			t.Fatalf("unexpected error while preparing solver: %s", err)
+		}
+		soln, err := s.Solve()
+		if err != nil {
+			t.Fatalf("(concurrency %v) unexpected error while solving: %s", n, err)
+		}
+
+		lps := soln.Projects()
+		sort.Sort(lpsorter(lps))
+		return lps, sm
+	}
+
+	want, sm := solve(-1)
+	if sm.calls != 0 {
+		t.Errorf("expected no speculative requests with prefetching disabled, got %v", sm.calls)
+	}
+
+	got, sm := solve(2)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("prefetching changed the solution:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+	if sm.calls == 0 {
+		t.Error("expected speculative requests to be made")
+	}
+	if sm.maxRunning > 2 {
+		t.Errorf("expected no more than 2 speculative requests at once, got %v", sm.maxRunning)
+	}
+}
+
+// stuckSM is a SourceManager whose speculative requests hang, ignoring their
+// context, as they would through a ctxAdapter. Once the first has started,
+// manifest fetches are held until the solve is canceled.
+type stuckSM struct {
+	*depspecSourceManager
+	once             sync.Once
+	started, release chan struct{}
+}
+
+func (sm *stuckSM) ListPackagesContext(ctx context.Context, id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	sm.once.Do(func() { close(sm.started) })
+	<-sm.release
+	return sm.depspecSourceManager.ListPackagesContext(ctx, id, v)
+}
+
+func (sm *stuckSM) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	select {
+	case <-sm.started:
+		<-ctx.Done()
+	case <-time.After(5 * time.Millisecond):
+	}
+	return sm.depspecSourceManager.GetManifestAndLockContext(ctx, id, v, an)
+}
+
+func TestSolveCanceledDoesNotWaitForPrefetch(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+	sm := &stuckSM{
+		depspecSourceManager: newdepspecSM(fix.ds, nil),
+		started:              make(chan struct{}),
+		release:              make(chan struct{}),
+	}
+	defer close(sm.release)
+
+	params := SolveParameters{
+		RootDir:             string(fix.ds[0].n),
+		RootPackageTree:     fix.rootTree(),
+		Manifest:            fix.rootmanifest(),
+		ProjectAnalyzer:     naiveAnalyzer{},
+		PrefetchConcurrency: 2,
+	}
+
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-sm.started
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.SolveContext(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if _, ok := err.(*SolveCanceledError); !ok {
+			t.Errorf("expected a *SolveCanceledError, got %T: %v", err, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled solve waited for a speculative request that could not be canceled")
+	}
+}
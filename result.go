@@ -13,6 +13,28 @@ type Solution interface {
 	Attempts() int
 }
 
+// A RetainedSolution is a Solution that retains detail about the solve run
+// that produced it, so that it can explain itself, and be built on by a later
+// run. The Solution returned by a solver is a RetainedSolution only if
+// SolveParameters.Retain was set:
+//
+//	if rs, ok := soln.(gps.RetainedSolution); ok {
+//		dr, err := rs.Why("github.com/example/lib")
+//		...
+//	}
+type RetainedSolution interface {
+	Solution
+
+	// Why explains why the project or package at the given path is in the
+	// solution: the chains of imports that reach it from the root project,
+	// and the constraints that the selected version satisfies.
+	Why(path string) (DependencyReason, error)
+
+	// WhyNotNewer reports why the given project is not at the newest of its
+	// versions, as listed by the provided SourceManager.
+	WhyNotNewer(pr ProjectRoot, sm SourceManager) (NewerVersionReport, error)
+}
+
 type solution struct {
 	// A list of the projects selected by the solver.
 	p []LockedProject
@@ -24,6 +46,24 @@ type solution struct {
 	hd []byte
 }
 
+// retainedSolution is a solution along with the detail kept when
+// SolveParameters.Retain is set.
+type retainedSolution struct {
+	solution
+
+	// The dependency graph among the selected projects
+	g *depGraph
+
+	// What the solver knew about each selected project, and how it chose
+	// among acceptable versions
+	held map[ProjectRoot]heldData
+	mode heldSolveMode
+
+	// The inputs to the solve run, and the data retrieved for the selected
+	// atoms, for use by later runs that build on this one
+	rec *solveRecord
+}
+
 // WriteDepTree takes a basedir and a Lock, and exports all the projects
 // listed in the lock to the appropriate target location within the basedir.
 //
@@ -72,3 +112,15 @@ func (r solution) Attempts() int {
 func (r solution) InputHash() []byte {
 	return r.hd
 }
+
+func (r retainedSolution) Why(path string) (DependencyReason, error) {
+	return r.g.why(path)
+}
+
+func (r retainedSolution) WhyNotNewer(pr ProjectRoot, sm SourceManager) (NewerVersionReport, error) {
+	hd, has := r.held[pr]
+	if !has {
+		return NewerVersionReport{}, fmt.Errorf("%s is not in the solution", pr)
+	}
+	return hd.whyNotNewer(sm, r.mode)
+}
@@ -14,16 +14,37 @@ type rootdata struct {
 	// Path to the root of the project on which gps is operating.
 	dir string
 
-	// Map of packages to ignore.
+	// Map of packages to ignore. With several workspace roots, these are the
+	// packages every root ignores, which are ignored outside the roots.
 	ig map[string]bool
 
-	// Map of packages to require.
+	// Map of packages to require, from all the workspace roots.
 	req map[string]bool
 
+	// The root projects in the workspace: the root project itself first,
+	// followed by any others. Each has its own ignored and required packages.
+	ws []localRoot
+
+	// The platforms and build tags to restrict imports to. If empty, all
+	// imports are followed.
+	targets []pkgtree.Target
+
+	// The Go release whose standard library is excluded from solving, or
+	// internal.StdLibHeuristic if none was given in the SolveParameters.
+	gover string
+
+	// The minor version of the Go release given in the SolveParameters,
+	// against which dependencies' minimum Go versions are checked, or -1 if
+	// none was given.
+	gominor int
+
 	// A ProjectConstraints map containing the validated (guaranteed non-empty)
 	// overrides declared by the root manifest.
 	ovr ProjectConstraints
 
+	// Rules denying the use of particular versions, indexed by ProjectRoot.
+	deny denyRules
+
 	// A map of the ProjectRoot (local names) that should be allowed to change
 	chng map[ProjectRoot]struct{}
 
@@ -34,7 +55,8 @@ type rootdata struct {
 	// A map of the project names listed in the root's lock.
 	rlm map[ProjectRoot]LockedProject
 
-	// A defensively copied instance of the root manifest.
+	// A defensively copied instance of the root manifest, combined with those
+	// of any other workspace roots.
 	rm SimpleManifest
 
 	// A defensively copied instance of the root lock.
@@ -47,34 +69,37 @@ type rootdata struct {
 	an ProjectAnalyzer
 }
 
+// isStdLib indicates whether the import path is of a standard library package
+// in the Go release being solved for.
+func (rd rootdata) isStdLib(path string) bool {
+	return internal.IsStdLib(path, rd.gover)
+}
+
 // externalImportList returns a list of the unique imports from the root data.
 // Ignores and requires are taken into consideration, stdlib is excluded, and
-// errors within the local set of package are not backpropagated.
+// errors within the local set of package are not backpropagated. Imports
+// between workspace roots are local, and so are excluded, too.
 func (rd rootdata) externalImportList() []string {
-	rm, _ := rd.rpt.ToReachMap(true, true, false, rd.ig)
-	all := rm.Flatten(false)
-	reach := make([]string, 0, len(all))
-	for _, r := range all {
-		if !internal.IsStdLib(r) {
-			reach = append(reach, r)
-		}
-	}
-
-	// If there are any requires, slide them into the reach list, as well.
-	if len(rd.req) > 0 {
-		// Make a map of imports that are both in the import path list and the
-		// required list to avoid duplication.
-		skip := make(map[string]bool, len(rd.req))
-		for _, r := range reach {
-			if rd.req[r] {
-				skip[r] = true
+	var reach []string
+	seen := make(map[string]bool)
+	for _, lr := range rd.ws {
+		// Each root's ignores apply to its own packages.
+		rm, _ := lr.rpt.ToReachMapFor(true, true, false, lr.ig, rd.targets)
+		// Stdlib imports are kept in the flattening, as only the Go release
+		// being solved for can say which they are.
+		for _, r := range rm.Flatten(true) {
+			if !seen[r] && !rd.isStdLib(r) && !rd.isLocal(r) {
+				seen[r] = true
+				reach = append(reach, r)
 			}
 		}
+	}
 
-		for r := range rd.req {
-			if !skip[r] {
-				reach = append(reach, r)
-			}
+	// If there are any requires, slide them into the reach list, as well,
+	// skipping those that are already in it.
+	for r := range rd.req {
+		if !seen[r] && !rd.isLocal(r) {
+			reach = append(reach, r)
 		}
 	}
 
@@ -117,7 +142,7 @@ func (rd rootdata) getApplicableConstraints() []workingConstraint {
 	// Walk all dep import paths we have to consider and mark the corresponding
 	// wc entry in the trie, if any
 	for _, im := range rd.externalImportList() {
-		if internal.IsStdLib(im) {
+		if rd.isStdLib(im) {
 			continue
 		}
 
@@ -176,8 +201,13 @@ func (rd rootdata) needVersionsFor(pr ProjectRoot) bool {
 
 }
 
+// isRoot indicates whether the project is one of the workspace roots.
 func (rd rootdata) isRoot(pr ProjectRoot) bool {
-	return pr == ProjectRoot(rd.rpt.ImportRoot)
+	if pr == ProjectRoot(rd.rpt.ImportRoot) {
+		return true
+	}
+	_, is := rd.localRoot(pr)
+	return is
 }
 
 // rootAtom creates an atomWithPackages that represents the root project.
@@ -193,10 +223,12 @@ func (rd rootdata) rootAtom() atomWithPackages {
 		v: rootRev,
 	}
 
-	list := make([]string, 0, len(rd.rpt.Packages))
-	for path, pkg := range rd.rpt.Packages {
-		if pkg.Err != nil && !rd.ig[path] {
-			list = append(list, path)
+	var list []string
+	for _, lr := range rd.ws {
+		for path, pkg := range lr.rpt.Packages {
+			if pkg.Err != nil && !lr.ig[path] {
+				list = append(list, path)
+			}
 		}
 	}
 	sort.Strings(list)
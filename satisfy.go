@@ -1,5 +1,7 @@
 package gps
 
+import "github.com/sdboyer/gps/internal"
+
 // check performs constraint checks on the provided atom. The set of checks
 // differ slightly depending on whether the atom is pkgonly, or if it's the
 // entire project being added for the first time.
@@ -18,11 +20,23 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 	// If we're pkgonly, then base atom was already determined to be allowable,
 	// so we can skip the checkAtomAllowable step.
 	if !pkgonly {
+		if err := s.checkAtomNotDenied(pa); err != nil {
+			s.traceInfo(err)
+			s.mtr.pop()
+			return err
+		}
 		if err := s.checkAtomAllowable(pa); err != nil {
 			s.traceInfo(err)
 			s.mtr.pop()
 			return err
 		}
+		// This fetches the atom's manifest, so only do it once the cheaper
+		// checks have passed.
+		if err := s.checkAtomGoVersion(pa); err != nil {
+			s.traceInfo(err)
+			s.mtr.pop()
+			return err
+		}
 	}
 
 	if err := s.checkRequiredPackagesExist(a); err != nil {
@@ -103,6 +117,34 @@ func (s *solver) checkAtomAllowable(pa atom) error {
 	return err
 }
 
+// checkAtomGoVersion ensures that the atom's manifest does not declare a
+// minimum Go version newer than the one given in the SolveParameters.
+func (s *solver) checkAtomGoVersion(pa atom) error {
+	if s.rd.gominor < 0 {
+		return nil
+	}
+
+	m, _, err := s.b.GetManifestAndLock(pa.id, pa.v, s.rd.an)
+	if err != nil {
+		return err
+	}
+	gm, ok := m.(GoVersionManifest)
+	if !ok || gm.MinimumGoVersion() == "" {
+		return nil
+	}
+
+	// A minimum that can't be parsed can't be enforced.
+	min, err := internal.GoMinor(gm.MinimumGoVersion())
+	if err != nil || min <= s.rd.gominor {
+		return nil
+	}
+	return &goVersionFailure{
+		goal:   pa,
+		min:    gm.MinimumGoVersion(),
+		target: s.rd.gover,
+	}
+}
+
 // checkRequiredPackagesExist ensures that all required packages enumerated by
 // existing dependencies on this atom are actually present in the atom.
 func (s *solver) checkRequiredPackagesExist(a atomWithPackages) error {
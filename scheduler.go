@@ -0,0 +1,187 @@
+package gps
+
+import (
+	"context"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ConcurrencyLimits controls how many calls a SourceMgr will run at once.
+//
+// Calls are divided into two classes. Network-bound calls - go-get metadata
+// requests, and VCS operations that talk to an upstream, like cloning,
+// fetching and listing remote versions - are subject to a global limit and a
+// per-host limit. Local, CPU-bound calls - listing packages, deriving
+// manifests and locks, and exporting trees - are subject to their own,
+// separate limit.
+//
+// For all fields, a value of zero or less means no limit.
+type ConcurrencyLimits struct {
+	// Network is the maximum number of network-bound calls that may run at
+	// once, across all hosts.
+	Network int
+
+	// PerHost is the maximum number of network-bound calls that may run at
+	// once against any single host.
+	PerHost int
+
+	// Hosts overrides PerHost for particular hosts, keyed by host as it
+	// appears in source URLs (e.g. "github.com", or "example.com:8080").
+	Hosts map[string]int
+
+	// CPU is the maximum number of local, CPU-bound calls that may run at
+	// once.
+	CPU int
+}
+
+// DefaultConcurrencyLimits returns the ConcurrencyLimits used by a new
+// SourceMgr. They're chosen to keep large solves from hammering any single
+// host (and tripping rate limiting) while still allowing useful parallelism.
+func DefaultConcurrencyLimits() ConcurrencyLimits {
+	return ConcurrencyLimits{
+		Network: 16,
+		PerHost: 8,
+		CPU:     runtime.NumCPU(),
+	}
+}
+
+// scheduler gates the execution of supervised calls according to a set of
+// ConcurrencyLimits.
+//
+// Limits are implemented as buffered channels used as counting semaphores. A
+// nil channel means no limit. Replacing the limits replaces the channels;
+// calls already holding slots release them back to the channels they were
+// acquired from, so in-flight work is unaffected.
+type scheduler struct {
+	mu    sync.Mutex // guards all fields below
+	lim   ConcurrencyLimits
+	net   chan struct{}
+	cpu   chan struct{}
+	hosts map[string]chan struct{}
+}
+
+func newScheduler(lim ConcurrencyLimits) *scheduler {
+	s := &scheduler{}
+	s.setLimits(lim)
+	return s
+}
+
+func (s *scheduler) setLimits(lim ConcurrencyLimits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Copy the host map so later changes by the caller don't leak in.
+	hl := make(map[string]int, len(lim.Hosts))
+	for h, n := range lim.Hosts {
+		hl[strings.ToLower(h)] = n
+	}
+	lim.Hosts = hl
+
+	s.lim = lim
+	s.net = mkSemaphore(lim.Network)
+	s.cpu = mkSemaphore(lim.CPU)
+	s.hosts = make(map[string]chan struct{})
+}
+
+func (s *scheduler) limits() ConcurrencyLimits {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lim
+}
+
+// acquire waits until a call of the given type, against the given host, may
+// run. It returns a func that must be called to release the acquired slots
+// when the call completes.
+//
+// host may be empty, in which case only the global limit is applied to
+// network-bound calls.
+func (s *scheduler) acquire(ctx context.Context, typ callType, host string) (func(), error) {
+	s.mu.Lock()
+	var sems []chan struct{}
+	if typ.isNetwork() {
+		// Take the host slot before the global one, so that calls queued up
+		// behind a busy host don't sit on global slots that calls to other
+		// hosts could be using.
+		if host != "" {
+			if hs := s.hostSemaphore(strings.ToLower(host)); hs != nil {
+				sems = append(sems, hs)
+			}
+		}
+		if s.net != nil {
+			sems = append(sems, s.net)
+		}
+	} else if s.cpu != nil {
+		sems = append(sems, s.cpu)
+	}
+	s.mu.Unlock()
+
+	for k, sem := range sems {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			releaseSemaphores(sems[:k])
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() { releaseSemaphores(sems) }, nil
+}
+
+// hostSemaphore returns the semaphore for the given host, creating it if
+// necessary. Assumes s.mu is held.
+func (s *scheduler) hostSemaphore(host string) chan struct{} {
+	if sem, has := s.hosts[host]; has {
+		return sem
+	}
+
+	n, has := s.lim.Hosts[host]
+	if !has {
+		n = s.lim.PerHost
+	}
+
+	sem := mkSemaphore(n)
+	s.hosts[host] = sem
+	return sem
+}
+
+func mkSemaphore(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+func releaseSemaphores(sems []chan struct{}) {
+	// Release in reverse order of acquisition.
+	for k := len(sems) - 1; k >= 0; k-- {
+		<-sems[k]
+	}
+}
+
+// isNetwork indicates whether calls of this type are expected to talk to a
+// remote host.
+func (ct callType) isNetwork() bool {
+	switch ct {
+	case ctHTTPMetadata, ctListVersions, ctSourcePing, ctSourceInit, ctSourceFetch:
+		return true
+	default:
+		return false
+	}
+}
+
+// hostOf extracts the host (including port, if any) from a source URL. Both
+// regular URLs and SCP-like syntax (e.g. "git@github.com:user/repo") are
+// accepted. It returns the empty string if no host can be determined.
+func hostOf(u string) string {
+	if m := scpSyntaxRe.FindStringSubmatch(u); m != nil {
+		return m[2]
+	}
+
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return pu.Host
+}
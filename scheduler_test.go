@@ -0,0 +1,124 @@
+package gps
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedulerLimits(t *testing.T) {
+	s := newScheduler(ConcurrencyLimits{
+		Network: 3,
+		PerHost: 2,
+		Hosts:   map[string]int{"Example.com": 1},
+		CPU:     1,
+	})
+
+	bgc := context.Background()
+	mustAcquire := func(typ callType, host string) func() {
+		rel, err := s.acquire(bgc, typ, host)
+		if err != nil {
+			t.Fatalf("unexpected error acquiring %v for %q: %s", typ, host, err)
+		}
+		return rel
+	}
+	blocked := func(typ callType, host string) bool {
+		ctx, cancel := context.WithTimeout(bgc, 20*time.Millisecond)
+		defer cancel()
+		rel, err := s.acquire(ctx, typ, host)
+		if err == nil {
+			rel()
+			return false
+		}
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline error while blocked, got %s", err)
+		}
+		return true
+	}
+
+	// Per-host limit
+	r1 := mustAcquire(ctSourceFetch, "github.com")
+	r2 := mustAcquire(ctListVersions, "github.com")
+	if !blocked(ctSourcePing, "github.com") {
+		t.Error("third call to github.com should have been blocked by per-host limit")
+	}
+
+	// Host override, matched case-insensitively
+	r3 := mustAcquire(ctHTTPMetadata, "example.com")
+	if !blocked(ctHTTPMetadata, "EXAMPLE.COM") {
+		t.Error("second call to example.com should have been blocked by host override")
+	}
+
+	// Global network limit; three slots are now taken
+	if !blocked(ctSourceInit, "bitbucket.org") {
+		t.Error("call to bitbucket.org should have been blocked by global network limit")
+	}
+	if !blocked(ctSourceInit, "") {
+		t.Error("call with no host should have been blocked by global network limit")
+	}
+
+	// CPU-bound calls are limited separately
+	rc := mustAcquire(ctListPackages, "")
+	if !blocked(ctGetManifestAndLock, "") {
+		t.Error("second CPU-bound call should have been blocked by CPU limit")
+	}
+	rc()
+
+	r1()
+	if blocked(ctSourceInit, "bitbucket.org") {
+		t.Error("call to bitbucket.org should have proceeded after a global slot was released")
+	}
+	r2()
+	r3()
+
+	// Changing limits does not disturb in-flight calls
+	r4 := mustAcquire(ctListPackages, "")
+	s.setLimits(ConcurrencyLimits{})
+	if blocked(ctListPackages, "") {
+		t.Error("calls should not be blocked after removing all limits")
+	}
+	r4()
+}
+
+func TestSupervisorHonorsSchedulerCancel(t *testing.T) {
+	superv := newSupervisor(context.Background())
+	superv.sched.setLimits(ConcurrencyLimits{PerHost: 1})
+
+	block, started := make(chan struct{}), make(chan struct{})
+	go superv.doHost(context.Background(), "github.com", "foo", ctSourceFetch, func(ctx context.Context) error {
+		close(started)
+		<-block
+		return nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := superv.doHost(ctx, "github.com", "bar", ctSourceFetch, func(ctx context.Context) error {
+		t.Error("call should not have run while the host limit was saturated")
+		return nil
+	})
+	if err == nil {
+		t.Error("expected an error from a call that timed out waiting on the scheduler")
+	}
+
+	close(block)
+	superv.wait()
+}
+
+func TestHostOf(t *testing.T) {
+	table := map[string]string{
+		"https://github.com/sdboyer/gps":   "github.com",
+		"ssh://git@github.com/sdboyer/gps": "github.com",
+		"git@github.com:sdboyer/gps":       "github.com",
+		"http://example.com:8080/foo/bar":  "example.com:8080",
+		"github.com/sdboyer/gps":           "",
+		"/home/sdboyer/src/gps":            "",
+	}
+
+	for in, want := range table {
+		if got := hostOf(in); got != want {
+			t.Errorf("hostOf(%q): wanted %q, got %q", in, want, got)
+		}
+	}
+}
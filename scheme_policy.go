@@ -0,0 +1,196 @@
+package gps
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SchemeRule describes which URL schemes may be used to access sources, and
+// the order in which they are preferred.
+type SchemeRule struct {
+	// Order lists schemes in order of preference. When gps has a choice of
+	// URLs for a source, those with schemes listed earlier are tried first.
+	// URLs with schemes not listed are tried after all listed ones, in their
+	// original order.
+	//
+	// If nil, the default order for each type of source is used - for git,
+	// that's https, ssh, git, then http.
+	Order []string
+
+	// Allow is the set of schemes which may be used. URLs with any other
+	// scheme are never used to access a source.
+	//
+	// If nil, all schemes are allowed.
+	Allow []string
+}
+
+func (r SchemeRule) allows(scheme string) bool {
+	if r.Allow == nil {
+		return true
+	}
+
+	for _, s := range r.Allow {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+func (r SchemeRule) rank(scheme string) int {
+	for k, s := range r.Order {
+		if s == scheme {
+			return k
+		}
+	}
+	return len(r.Order)
+}
+
+// SchemePolicy controls which URL schemes a SourceMgr may use to access
+// sources, both globally and for individual hosts.
+//
+// The policy also applies to the retrieval of go-get metadata: if http is not
+// allowed for a host, metadata is only ever fetched from it over https.
+//
+// For example, to forbid unauthenticated transports everywhere, and require
+// ssh for one host:
+//
+//	SchemePolicy{
+//		Default: SchemeRule{Allow: []string{"https", "ssh", "bzr+ssh"}},
+//		Hosts: map[string]SchemeRule{
+//			"git.example.com": {Allow: []string{"ssh"}},
+//		},
+//	}
+type SchemePolicy struct {
+	// Default is the rule applied to all hosts.
+	Default SchemeRule
+
+	// Hosts contains rules for particular hosts, keyed by host as it appears
+	// in source URLs (e.g. "github.com"). Any nil field in a host's rule is
+	// inherited from Default.
+	Hosts map[string]SchemeRule
+}
+
+// schemePolicy holds the SchemePolicy for a SourceMgr. A nil *schemePolicy
+// allows everything, and changes nothing.
+type schemePolicy struct {
+	mu sync.RWMutex
+	p  SchemePolicy
+}
+
+func (sp *schemePolicy) set(p SchemePolicy) {
+	// Copy the host map so later changes by the caller don't leak in.
+	hosts := make(map[string]SchemeRule, len(p.Hosts))
+	for h, r := range p.Hosts {
+		hosts[strings.ToLower(h)] = r
+	}
+	p.Hosts = hosts
+
+	sp.mu.Lock()
+	sp.p = p
+	sp.mu.Unlock()
+}
+
+func (sp *schemePolicy) get() SchemePolicy {
+	if sp == nil {
+		return SchemePolicy{}
+	}
+
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	return sp.p
+}
+
+// rule returns the effective rule for the given host.
+func (sp *schemePolicy) rule(host string) SchemeRule {
+	p := sp.get()
+	r := p.Default
+	if hr, has := p.Hosts[strings.ToLower(host)]; has {
+		if hr.Order != nil {
+			r.Order = hr.Order
+		}
+		if hr.Allow != nil {
+			r.Allow = hr.Allow
+		}
+	}
+	return r
+}
+
+// apply filters and reorders the candidate sources in a maybeSource according
+// to the policy. An error is returned if no candidates are allowed.
+func (sp *schemePolicy) apply(mb maybeSource) (maybeSource, error) {
+	allowed, denied := sp.filter(flattenMaybeSources(nil, mb))
+
+	switch len(allowed) {
+	case 0:
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "no candidate source is permitted by the scheme policy:")
+		for _, m := range denied {
+			fmt.Fprintf(&buf, "\n\t%s", m.getURL())
+		}
+		return nil, errors.New(buf.String())
+	case 1:
+		return allowed[0], nil
+	default:
+		return maybeSources(allowed), nil
+	}
+}
+
+// filter splits the provided candidates into those allowed by the policy, in
+// order of preference, and those denied.
+func (sp *schemePolicy) filter(mbs []maybeSource) (allowed, denied []maybeSource) {
+	var ranks []int
+	for _, mb := range mbs {
+		u := maybeSourceURL(mb)
+		if u == nil {
+			// Shouldn't be possible, but don't hide sources we can't inspect.
+			allowed = append(allowed, mb)
+			ranks = append(ranks, 0)
+			continue
+		}
+
+		r := sp.rule(u.Host)
+		if !r.allows(u.Scheme) {
+			denied = append(denied, mb)
+			continue
+		}
+		allowed = append(allowed, mb)
+		ranks = append(ranks, r.rank(u.Scheme))
+	}
+
+	sort.Stable(rankedMaybes{mbs: allowed, ranks: ranks})
+	return allowed, denied
+}
+
+type rankedMaybes struct {
+	mbs   []maybeSource
+	ranks []int
+}
+
+func (r rankedMaybes) Len() int           { return len(r.mbs) }
+func (r rankedMaybes) Less(i, j int) bool { return r.ranks[i] < r.ranks[j] }
+func (r rankedMaybes) Swap(i, j int) {
+	r.mbs[i], r.mbs[j] = r.mbs[j], r.mbs[i]
+	r.ranks[i], r.ranks[j] = r.ranks[j], r.ranks[i]
+}
+
+// maybeSourceURL returns the URL that a single (non-composite) maybeSource
+// would access, or nil if it is of an unknown type.
+func maybeSourceURL(mb maybeSource) *url.URL {
+	switch tmb := mb.(type) {
+	case maybeGitSource:
+		return tmb.url
+	case maybeGopkginSource:
+		return tmb.url
+	case maybeBzrSource:
+		return tmb.url
+	case maybeHgSource:
+		return tmb.url
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package gps
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSchemePolicyFilter(t *testing.T) {
+	gh := maybeSources{
+		maybeGitSource{url: mkurl("https://github.com/sdboyer/gps")},
+		maybeGitSource{url: mkurl("ssh://git@github.com/sdboyer/gps")},
+		maybeGitSource{url: mkurl("git://github.com/sdboyer/gps")},
+		maybeGitSource{url: mkurl("http://github.com/sdboyer/gps")},
+	}
+	bb := maybeSources{
+		maybeGitSource{url: mkurl("https://bitbucket.org/sdboyer/gps")},
+		maybeGitSource{url: mkurl("ssh://git@bitbucket.org/sdboyer/gps")},
+		maybeGitSource{url: mkurl("git://bitbucket.org/sdboyer/gps")},
+		maybeGitSource{url: mkurl("http://bitbucket.org/sdboyer/gps")},
+	}
+
+	sp := &schemePolicy{}
+	sp.set(SchemePolicy{
+		Default: SchemeRule{
+			Order: []string{"ssh", "https"},
+			Allow: []string{"https", "ssh", "git"},
+		},
+		Hosts: map[string]SchemeRule{
+			// Case should not matter, and Order is inherited from Default.
+			"GitHub.com": {Allow: []string{"https", "ssh"}},
+		},
+	})
+
+	table := []struct {
+		name    string
+		in      maybeSources
+		allowed []string
+		denied  []string
+	}{
+		{
+			name: "host rule",
+			in:   gh,
+			allowed: []string{
+				"ssh://git@github.com/sdboyer/gps",
+				"https://github.com/sdboyer/gps",
+			},
+			denied: []string{
+				"git://github.com/sdboyer/gps",
+				"http://github.com/sdboyer/gps",
+			},
+		},
+		{
+			name: "default rule",
+			in:   bb,
+			allowed: []string{
+				"ssh://git@bitbucket.org/sdboyer/gps",
+				"https://bitbucket.org/sdboyer/gps",
+				"git://bitbucket.org/sdboyer/gps",
+			},
+			denied: []string{
+				"http://bitbucket.org/sdboyer/gps",
+			},
+		},
+	}
+
+	urls := func(mbs []maybeSource) []string {
+		var s []string
+		for _, mb := range mbs {
+			s = append(s, maybeSourceURL(mb).String())
+		}
+		return s
+	}
+
+	for _, fix := range table {
+		allowed, denied := sp.filter(flattenMaybeSources(nil, fix.in))
+		if got := urls(allowed); strings.Join(got, " ") != strings.Join(fix.allowed, " ") {
+			t.Errorf("%s: expected allowed %v, got %v", fix.name, fix.allowed, got)
+		}
+		if got := urls(denied); strings.Join(got, " ") != strings.Join(fix.denied, " ") {
+			t.Errorf("%s: expected denied %v, got %v", fix.name, fix.denied, got)
+		}
+	}
+
+	// A nil policy changes nothing.
+	var nilsp *schemePolicy
+	allowed, denied := nilsp.filter(flattenMaybeSources(nil, gh))
+	if len(allowed) != 4 || len(denied) != 0 {
+		t.Errorf("nil policy should allow everything, got %v allowed and %v denied", len(allowed), len(denied))
+	}
+}
+
+func TestSchemePolicyApply(t *testing.T) {
+	sp := &schemePolicy{}
+	sp.set(SchemePolicy{Default: SchemeRule{Allow: []string{"ssh"}}})
+
+	mb, err := sp.apply(maybeSources{
+		maybeGitSource{url: mkurl("https://github.com/sdboyer/gps")},
+		maybeGitSource{url: mkurl("ssh://git@github.com/sdboyer/gps")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if gmb, ok := mb.(maybeGitSource); !ok || gmb.url.Scheme != "ssh" {
+		t.Errorf("expected a lone ssh maybeGitSource, got %#v", mb)
+	}
+
+	_, err = sp.apply(maybeGitSource{url: mkurl("https://github.com/sdboyer/gps")})
+	if err == nil {
+		t.Error("expected an error when no candidate is permitted")
+	}
+}
+
+func TestFetchMetadataHonorsSchemeRule(t *testing.T) {
+	// .invalid is reserved, so any fetch that is actually attempted fails
+	// with a different error.
+	_, err := fetchMetadata(context.Background(), "vanity.invalid/foo", "http", SchemeRule{Allow: []string{"https"}})
+	if err == nil || !strings.Contains(err.Error(), "scheme policy") {
+		t.Errorf("expected http fetch to be refused by the scheme rule, got %v", err)
+	}
+
+	_, err = fetchMetadata(context.Background(), "vanity.invalid/foo", "", SchemeRule{Allow: []string{"ssh"}})
+	if err == nil || !strings.Contains(err.Error(), "scheme policy") {
+		t.Errorf("expected fetch to be refused by the scheme rule, got %v", err)
+	}
+}
+
+func TestSourceMgrSchemePolicy(t *testing.T) {
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	sm.SetSchemePolicy(SchemePolicy{Default: SchemeRule{Allow: []string{"file"}}})
+	if p := sm.SchemePolicy(); len(p.Default.Allow) != 1 || p.Default.Allow[0] != "file" {
+		t.Errorf("unexpected policy returned: %+v", p)
+	}
+
+	// github.com is deduced statically, so this requires no network.
+	_, err := sm.ListVersions(mkPI("github.com/sdboyer/gpkt"))
+	if err == nil || !strings.Contains(err.Error(), "scheme policy") {
+		t.Errorf("expected source setup to fail due to the scheme policy, got %v", err)
+	}
+}
@@ -147,6 +147,27 @@ func (s *selection) selected(id ProjectIdentifier) (atomWithPackages, bool) {
 	return atomWithPackages{a: nilpa}, false
 }
 
+// combined merges the selected projects, excluding the root, with all of the
+// packages selected from each.
+func (s *selection) combined() map[atom]map[string]struct{} {
+	projs := make(map[atom]map[string]struct{})
+
+	// Skip the first project. It's always the root, and that shouldn't be
+	// included in results.
+	for _, sel := range s.projects[1:] {
+		pm, exists := projs[sel.a.a]
+		if !exists {
+			pm = make(map[string]struct{})
+			projs[sel.a.a] = pm
+		}
+
+		for _, path := range sel.a.pl {
+			pm[path] = struct{}{}
+		}
+	}
+	return projs
+}
+
 type unselected struct {
 	sl  []bimodalIdentifier
 	cmp func(i, j int) bool
@@ -1,6 +1,7 @@
 package gps
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -192,6 +193,8 @@ type depspec struct {
 	deps    []ProjectConstraint
 	devdeps []ProjectConstraint
 	pkgs    []tpkg
+	// The minimum Go version declared by the manifest, if any.
+	mingo string
 }
 
 // mkDepspec creates a depspec by processing a series of strings, each of which
@@ -230,6 +233,12 @@ func mkDepspec(pi string, deps ...string) depspec {
 	return ds
 }
 
+// goMin sets the minimum Go version declared by a depspec's manifest.
+func goMin(ds depspec, v string) depspec {
+	ds.mingo = v
+	return ds
+}
+
 func mkDep(atom, pdep string, pl ...string) dependency {
 	return dependency{
 		depender: mkAtom(atom),
@@ -404,6 +413,12 @@ type basicFixture struct {
 	maxAttempts int
 	// Use downgrade instead of default upgrade sorter
 	downgrade bool
+	// Use minimal version selection instead of searching
+	mvs bool
+	// versions to deny, if any
+	deny []DenyRule
+	// target Go version, if any
+	gover string
 	// lock file simulator, if one's to be used at all
 	l fixLock
 	// solve failure expected, if any
@@ -625,6 +640,231 @@ var basicFixtures = map[string]basicFixture{
 		changeall: true,
 		downgrade: true,
 	},
+	"mvs selects the maximum of the minimums": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a ^1.0.0", "b ^1.0.0"),
+			mkDepspec("a 1.0.0", "shared ^1.2.0"),
+			mkDepspec("a 1.1.0", "shared ^1.4.0"),
+			mkDepspec("b 1.0.0", "shared ^1.3.0"),
+			mkDepspec("b 1.1.0", "shared ^1.5.0"),
+			mkDepspec("shared 1.0.0"),
+			mkDepspec("shared 1.2.0"),
+			mkDepspec("shared 1.3.0"),
+			mkDepspec("shared 1.4.0"),
+			mkDepspec("shared 1.5.0"),
+		},
+		r: mksolution(
+			"a 1.0.0",
+			"b 1.0.0",
+			"shared 1.3.0",
+		),
+		mvs: true,
+	},
+	"mvs follows requirements of raised selections": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a ^1.0.0", "b ^1.0.0"),
+			mkDepspec("a 1.0.0"),
+			mkDepspec("a 1.1.0", "c ^1.1.0"),
+			mkDepspec("a 1.2.0", "c ^1.2.0"),
+			mkDepspec("b 1.0.0", "a ^1.1.0"),
+			mkDepspec("c 1.0.0"),
+			mkDepspec("c 1.1.0"),
+			mkDepspec("c 1.2.0"),
+		},
+		r: mksolution(
+			"a 1.1.0",
+			"b 1.0.0",
+			"c 1.1.0",
+		),
+		mvs: true,
+	},
+	"mvs ignores lock": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo >=1.0.1"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.0.1"),
+			mkDepspec("foo 1.0.2"),
+		},
+		l: mklock(
+			"foo 1.0.2",
+		),
+		r: mksolution(
+			"foo 1.0.1",
+		),
+		mvs: true,
+	},
+	"mvs fails on exceeded upper bound": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a 1.0.0", "b 1.0.0"),
+			mkDepspec("a 1.0.0", "shared >=1.0.0, <2.0.0"),
+			mkDepspec("b 1.0.0", "shared >=2.0.0"),
+			mkDepspec("shared 1.0.0"),
+			mkDepspec("shared 2.0.0"),
+		},
+		fail: &versionNotAllowedFailure{
+			goal:       mkAtom("shared 2.0.0"),
+			failparent: []dependency{mkDep("a 1.0.0", "shared >=1.0.0, <2.0.0", "shared")},
+			c:          none,
+		},
+		mvs: true,
+	},
+	"deny skips denied versions": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("foo 1.2.0"),
+		},
+		deny: []DenyRule{
+			{ProjectRoot: "foo", Constraint: NewVersion("1.2.0")},
+		},
+		r: mksolution(
+			"foo 1.1.0",
+		),
+	},
+	"deny skips denied locked version": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("foo 1.2.0"),
+		},
+		l: mklock(
+			"foo 1.1.0",
+		),
+		deny: []DenyRule{
+			{ProjectRoot: "foo", Constraint: mkSVC(">=1.1.0, <1.2.0")},
+		},
+		r: mksolution(
+			"foo 1.2.0",
+		),
+	},
+	"deny excludes every version": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ^1.0.0"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("foo 2.0.0"),
+		},
+		deny: []DenyRule{
+			{ProjectRoot: "foo", Constraint: mkSVC("<2.0.0"), Reason: "ADV-1"},
+		},
+		fail: &noVersionError{
+			pn: mkPI("foo"),
+			fails: []failedVersion{
+				{
+					v: NewVersion("2.0.0"),
+					f: &versionNotAllowedFailure{
+						goal:       mkAtom("foo 2.0.0"),
+						failparent: []dependency{mkDep("root", "foo ^1.0.0", "foo")},
+						c:          mkSVC("^1.0.0"),
+					},
+				},
+				{
+					v: NewVersion("1.1.0"),
+					f: &versionDeniedFailure{
+						goal: mkAtom("foo 1.1.0"),
+						rule: DenyRule{ProjectRoot: "foo", Constraint: mkSVC("<2.0.0"), Reason: "ADV-1"},
+					},
+				},
+				{
+					v: NewVersion("1.0.0"),
+					f: &versionDeniedFailure{
+						goal: mkAtom("foo 1.0.0"),
+						rule: DenyRule{ProjectRoot: "foo", Constraint: mkSVC("<2.0.0"), Reason: "ADV-1"},
+					},
+				},
+			},
+		},
+	},
+	"mvs skips denied minimum": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ^1.0.0"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("foo 1.2.0"),
+		},
+		deny: []DenyRule{
+			{ProjectRoot: "foo", Constraint: NewVersion("1.0.0")},
+		},
+		r: mksolution(
+			"foo 1.1.0",
+		),
+		mvs: true,
+	},
+	"minimum go version skips newer versions": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0"),
+			goMin(mkDepspec("foo 1.1.0"), "go1.9"),
+			goMin(mkDepspec("foo 1.2.0"), "go1.21"),
+		},
+		gover: "go1.18",
+		r: mksolution(
+			"foo 1.1.0",
+		),
+	},
+	"minimum go version unenforced without go version": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0"),
+			goMin(mkDepspec("foo 1.2.0"), "go1.99"),
+		},
+		r: mksolution(
+			"foo 1.2.0",
+		),
+	},
+	"minimum go version excludes every version": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ^1.0.0"),
+			goMin(mkDepspec("foo 1.0.0"), "go1.19"),
+			goMin(mkDepspec("foo 1.1.0"), "1.21.0"),
+			mkDepspec("foo 2.0.0"),
+		},
+		gover: "go1.18",
+		fail: &noVersionError{
+			pn: mkPI("foo"),
+			fails: []failedVersion{
+				{
+					v: NewVersion("2.0.0"),
+					f: &versionNotAllowedFailure{
+						goal:       mkAtom("foo 2.0.0"),
+						failparent: []dependency{mkDep("root", "foo ^1.0.0", "foo")},
+						c:          mkSVC("^1.0.0"),
+					},
+				},
+				{
+					v: NewVersion("1.1.0"),
+					f: &goVersionFailure{
+						goal:   mkAtom("foo 1.1.0"),
+						min:    "1.21.0",
+						target: "go1.18",
+					},
+				},
+				{
+					v: NewVersion("1.0.0"),
+					f: &goVersionFailure{
+						goal:   mkAtom("foo 1.0.0"),
+						min:    "go1.19",
+						target: "go1.18",
+					},
+				},
+			},
+		},
+	},
+	"mvs skips minimum needing newer go": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ^1.0.0"),
+			goMin(mkDepspec("foo 1.0.0"), "go1.21"),
+			goMin(mkDepspec("foo 1.1.0"), "go1.9"),
+			mkDepspec("foo 1.2.0"),
+		},
+		gover: "go1.18",
+		r: mksolution(
+			"foo 1.1.0",
+		),
+		mvs: true,
+	},
 	"update one with only one": {
 		ds: []depspec{
 			mkDepspec("root 0.0.0", "foo *"),
@@ -1361,7 +1601,7 @@ type depspecSourceManager struct {
 }
 
 type fixSM interface {
-	SourceManager
+	ContextSourceManager
 	rootSpec() depspec
 	allSpecs() []depspec
 	ignore() map[string]bool
@@ -1534,6 +1774,41 @@ func (sm *depspecSourceManager) DeduceProjectRoot(ip string) (ProjectRoot, error
 	return "", fmt.Errorf("Could not find %s, or any parent, in list of known fixtures", ip)
 }
 
+// The fixture sm does no real work, so the context-taking variants of its
+// methods need do nothing more than delegate.
+
+func (sm *depspecSourceManager) SourceExistsContext(ctx context.Context, id ProjectIdentifier) (bool, error) {
+	return sm.SourceExists(id)
+}
+
+func (sm *depspecSourceManager) SyncSourceForContext(ctx context.Context, id ProjectIdentifier) error {
+	return sm.SyncSourceFor(id)
+}
+
+func (sm *depspecSourceManager) ListVersionsContext(ctx context.Context, id ProjectIdentifier) ([]PairedVersion, error) {
+	return sm.ListVersions(id)
+}
+
+func (sm *depspecSourceManager) RevisionPresentInContext(ctx context.Context, id ProjectIdentifier, r Revision) (bool, error) {
+	return sm.RevisionPresentIn(id, r)
+}
+
+func (sm *depspecSourceManager) ListPackagesContext(ctx context.Context, id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	return sm.ListPackages(id, v)
+}
+
+func (sm *depspecSourceManager) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	return sm.GetManifestAndLock(id, v, an)
+}
+
+func (sm *depspecSourceManager) ExportProjectContext(ctx context.Context, id ProjectIdentifier, v Version, to string) error {
+	return sm.ExportProject(id, v, to)
+}
+
+func (sm *depspecSourceManager) DeduceProjectRootContext(ctx context.Context, ip string) (ProjectRoot, error) {
+	return sm.DeduceProjectRoot(ip)
+}
+
 func (sm *depspecSourceManager) rootSpec() depspec {
 	return sm.specs[0]
 }
@@ -1584,11 +1859,17 @@ func (b *depspecBridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 // override verifyRoot() on bridge to prevent any filesystem interaction
 func (b *depspecBridge) verifyRootDir(path string) error {
 	root := b.sm.(fixSM).rootSpec()
-	if string(root.n) != path {
-		return fmt.Errorf("Expected only root project %q to verifyRootDir(), got %q", root.n, path)
+	if string(root.n) == path {
+		return nil
+	}
+	// Workspace roots may be made from any of the other fixtures.
+	for _, ds := range b.sm.(fixSM).allSpecs() {
+		if string(ds.n) == path {
+			return nil
+		}
 	}
 
-	return nil
+	return fmt.Errorf("Expected only root project %q to verifyRootDir(), got %q", root.n, path)
 }
 
 func (b *depspecBridge) ListPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
@@ -1600,7 +1881,7 @@ func (b *depspecBridge) vendorCodeExists(id ProjectIdentifier) (bool, error) {
 }
 
 // enforce interfaces
-var _ Manifest = depspec{}
+var _ GoVersionManifest = depspec{}
 var _ Lock = dummyLock{}
 var _ Lock = fixLock{}
 
@@ -1614,6 +1895,10 @@ func (ds depspec) TestDependencyConstraints() ProjectConstraints {
 	return pcSliceToMap(ds.devdeps)
 }
 
+func (ds depspec) MinimumGoVersion() string {
+	return ds.mingo
+}
+
 type fixLock []LockedProject
 
 func (fixLock) SolverVersion() string {
@@ -1,6 +1,7 @@
 package gps
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -1148,6 +1149,14 @@ func (sm *bmSourceManager) GetManifestAndLock(id ProjectIdentifier, v Version, a
 	return nil, nil, fmt.Errorf("Project %s at version %s could not be found", id.errString(), v)
 }
 
+func (sm *bmSourceManager) ListPackagesContext(ctx context.Context, id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	return sm.ListPackages(id, v)
+}
+
+func (sm *bmSourceManager) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	return sm.GetManifestAndLock(id, v, an)
+}
+
 // computeBimodalExternalMap takes a set of depspecs and computes an
 // internally-versioned ReachMap that is useful for quickly answering
 // ReachMap.Flatten()-type calls.
@@ -2,9 +2,11 @@ package gps
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 type errorLevel uint8
@@ -220,6 +222,42 @@ func (e *versionNotAllowedFailure) traceString() string {
 	return buf.String()
 }
 
+// versionDeniedFailure describes a failure where an atom is rejected because
+// its version is denied by one of the DenyRules in the SolveParameters.
+type versionDeniedFailure struct {
+	// goal is the atom that was rejected.
+	goal atom
+	// rule is the DenyRule that denied the atom's version.
+	rule DenyRule
+}
+
+func (e *versionDeniedFailure) Error() string {
+	return fmt.Sprintf("Could not introduce %s, as it is denied by rule %s.", a2vs(e.goal), e.rule)
+}
+
+func (e *versionDeniedFailure) traceString() string {
+	return fmt.Sprintf("%s denied by rule %s", a2vs(e.goal), e.rule)
+}
+
+// goVersionFailure describes a failure where an atom is rejected because its
+// manifest declares a minimum Go version newer than the one being solved for.
+type goVersionFailure struct {
+	// goal is the atom that was rejected.
+	goal atom
+	// min is the minimum Go version declared by the atom's manifest.
+	min string
+	// target is the Go version given in the SolveParameters.
+	target string
+}
+
+func (e *goVersionFailure) Error() string {
+	return fmt.Sprintf("Could not introduce %s, as it requires Go %s, but the target is %s.", a2vs(e.goal), e.min, e.target)
+}
+
+func (e *goVersionFailure) traceString() string {
+	return fmt.Sprintf("%s requires Go %s, newer than target %s", a2vs(e.goal), e.min, e.target)
+}
+
 type missingSourceFailure struct {
 	goal ProjectIdentifier
 	prob string
@@ -235,6 +273,69 @@ func (e badOptsFailure) Error() string {
 	return string(e)
 }
 
+// SolveCanceledError is returned from a solving run that was stopped because
+// the context passed to Solver.SolveContext() was canceled, or its deadline
+// passed.
+type SolveCanceledError struct {
+	// Err is the error reported by the context - typically either
+	// context.Canceled or context.DeadlineExceeded.
+	Err error
+	// Attempts is the number of attempts the solver had made when it stopped.
+	Attempts int
+}
+
+func (e *SolveCanceledError) Error() string {
+	return fmt.Sprintf("solving stopped after %v attempts: %s", e.Attempts, e.Err)
+}
+
+// ErrAttemptsExceeded is the Err of a SolveBudgetError from a solving run that
+// made more attempts than SolveParameters.MaxAttempts allowed.
+var ErrAttemptsExceeded = errors.New("maximum number of attempts exceeded")
+
+// SolveBudgetError is returned from a solving run that was stopped because it
+// exceeded the Timeout or MaxAttempts given in its SolveParameters. It carries
+// what the run had found out by then, so that a run which can't be finished
+// can still be diagnosed.
+type SolveBudgetError struct {
+	// Err is context.DeadlineExceeded if the Timeout elapsed, or
+	// ErrAttemptsExceeded if there were too many attempts.
+	Err error
+	// Attempts is the number of attempts the solver had made when it stopped.
+	Attempts int
+	// Elapsed is how long the run took.
+	Elapsed time.Duration
+	// Partial is the deepest partial selection the solver reached - that is,
+	// the selected projects at the point when the most had been selected -
+	// sorted by project root.
+	Partial []LockedProject
+	// Failures are the failures that caused the solver to backtrack, in the
+	// order they occurred. Under minimal version selection, there are none;
+	// the first failure ends the run.
+	Failures []error
+}
+
+func (e *SolveBudgetError) Error() string {
+	return fmt.Sprintf("solving stopped after %v attempts in %s: %s (deepest partial selection had %v projects; %v failures)",
+		e.Attempts, e.Elapsed, e.Err, len(e.Partial), len(e.Failures))
+}
+
+// UnsolvableError is returned from a solving run that has determined that no
+// solution exists. In addition to the last failure encountered, it carries a
+// derivation explaining which projects' requirements conflict, and why.
+type UnsolvableError struct {
+	// Err is the last failure the solver encountered. On its own, it describes
+	// only the final project for which no version could be found.
+	Err error
+	// Derivation is the incompatibility proving that no solution exists. It
+	// has no Selections; the incompatibilities it was derived from lead back
+	// to the conflicts responsible.
+	Derivation *Incompatibility
+}
+
+func (e *UnsolvableError) Error() string {
+	return fmt.Sprintf("no solution could be found:\n%s", e.Derivation)
+}
+
 type sourceMismatchFailure struct {
 	// The ProjectRoot over which there is disagreement about where it should be
 	// sourced from
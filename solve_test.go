@@ -2,16 +2,19 @@ package gps
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/rand"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/sdboyer/gps/internal"
@@ -32,7 +35,7 @@ func init() {
 func overrideMkBridge() {
 	// For all tests, override the base bridge with the depspecBridge that skips
 	// verifyRootDir calls
-	mkBridge = func(s *solver, sm SourceManager, down bool) sourceBridge {
+	mkBridge = func(s *solver, sm ContextSourceManager, down bool) sourceBridge {
 		return &depspecBridge{
 			&bridge{
 				sm:     sm,
@@ -44,10 +47,13 @@ func overrideMkBridge() {
 	}
 }
 
+// Stores a reference to the original IsStdLib, so it can be restored.
+var doIsStdLib = internal.IsStdLib
+
 // sets the isStdLib func to always return false, otherwise it would identify
 // pretty much all of our fixtures as being stdlib and skip everything
 func overrideIsStdLib() {
-	internal.IsStdLib = func(path string) bool {
+	internal.IsStdLib = func(path, goVersion string) bool {
 		return false
 	}
 }
@@ -120,6 +126,9 @@ func solveBasicsAndCheck(fix basicFixture, t *testing.T) (res Solution, err erro
 		Manifest:        fix.rootmanifest(),
 		Lock:            dummyLock{},
 		Downgrade:       fix.downgrade,
+		MVS:             fix.mvs,
+		Deny:            fix.deny,
+		GoVersion:       fix.gover,
 		ChangeAll:       fix.changeall,
 		ToChange:        fix.changelist,
 		ProjectAnalyzer: naiveAnalyzer{},
@@ -198,6 +207,11 @@ func fixtureSolveSimpleChecks(fix specfix, soln Solution, err error, t *testing.
 	}
 
 	fixfail := fix.failure()
+	if uerr, ok := err.(*UnsolvableError); ok {
+		// Fixtures describe the underlying failure; the derivation is tested
+		// separately.
+		err = uerr.Err
+	}
 	if err != nil {
 		if fixfail == nil {
 			t.Errorf("Solve failed unexpectedly:\n%s", err)
@@ -435,6 +449,24 @@ func TestBadSolveOpts(t *testing.T) {
 	}
 
 	params.Lock, params.ToChange = nil, nil
+	params.Targets = []pkgtree.Target{{GOOS: "linux"}}
+	_, err = Prepare(params, sm)
+	if err == nil {
+		t.Errorf("Should have errored on target without GOARCH")
+	} else if !strings.Contains(err.Error(), "targets must specify both GOOS and GOARCH") {
+		t.Error("Prepare should have given error on target without GOARCH, but gave:", err)
+	}
+
+	params.Targets = nil
+	params.GoVersion = "go1.999"
+	_, err = Prepare(params, sm)
+	if err == nil {
+		t.Errorf("Should have errored on unknown Go version")
+	} else if !strings.Contains(err.Error(), "standard library of go1.999 is not known") {
+		t.Error("Prepare should have given error on unknown Go version, but gave:", err)
+	}
+
+	params.GoVersion = ""
 	_, err = Prepare(params, sm)
 	if err != nil {
 		t.Error("Basic conditions satisfied, prepare should have completed successfully, err as:", err)
@@ -442,7 +474,7 @@ func TestBadSolveOpts(t *testing.T) {
 
 	// swap out the test mkBridge override temporarily, just to make sure we get
 	// the right error
-	mkBridge = func(s *solver, sm SourceManager, down bool) sourceBridge {
+	mkBridge = func(s *solver, sm ContextSourceManager, down bool) sourceBridge {
 		return &bridge{
 			sm:     sm,
 			s:      s,
@@ -470,3 +502,364 @@ func TestBadSolveOpts(t *testing.T) {
 	// swap them back...not sure if this matters, but just in case
 	overrideMkBridge()
 }
+
+func TestSolveContextCanceled(t *testing.T) {
+	fix := basicFixtures["simple dependency tree"]
+	sm := newdepspecSM(fix.ds, nil)
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+	}
+
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatalf("Unexpected error while preparing solver: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = s.SolveContext(ctx)
+	if err == nil {
+		t.Fatal("Expected an error from solving with a canceled context")
+	}
+
+	cerr, ok := err.(*SolveCanceledError)
+	if !ok {
+		t.Fatalf("Expected *SolveCanceledError, got %T: %s", err, err)
+	}
+	if cerr.Err != context.Canceled {
+		t.Errorf("Expected wrapped error to be context.Canceled, got %s", cerr.Err)
+	}
+}
+
+func TestContextSourceManagerAdapter(t *testing.T) {
+	// A SourceManager that doesn't implement ContextSourceManager still needs
+	// to honor cancellation when used by the solver.
+	var sm SourceManager = struct{ SourceManager }{newdepspecSM(nil, nil)}
+	csm := toContextSourceManager(sm)
+	if _, ok := csm.(ctxAdapter); !ok {
+		t.Fatalf("Expected plain SourceManager to be wrapped in ctxAdapter, got %T", csm)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := csm.DeduceProjectRootContext(ctx, "foo"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from adapter, got %v", err)
+	}
+
+	dsm := newdepspecSM(nil, nil)
+	if toContextSourceManager(dsm) != ContextSourceManager(dsm) {
+		t.Error("Expected ContextSourceManager to be passed through unwrapped")
+	}
+}
+
+// windowsSM is a fixture SourceManager that marks some imports in the
+// package trees it returns as appearing only in Windows files.
+type windowsSM struct {
+	*bmSourceManager
+	windows map[string]string
+}
+
+func (sm windowsSM) ListPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	ptree, err := sm.bmSourceManager.ListPackages(id, v)
+	for path, poe := range ptree.Packages {
+		if imp, has := sm.windows[path]; has {
+			poe.P.ConstrainedImports = map[string][]string{"windows": {imp}}
+			ptree.Packages[path] = poe
+		}
+	}
+	return ptree, err
+}
+
+func TestSolveTargets(t *testing.T) {
+	fix := bimodalFixture{
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0"),
+				pkg("root", "a", "b"),
+			),
+			dsp(mkDepspec("a 1.0.0"), pkg("a", "c")),
+			dsp(mkDepspec("b 1.0.0"), pkg("b")),
+			dsp(mkDepspec("c 1.0.0"), pkg("c")),
+		},
+	}
+	// root only imports b, and a only imports c, on Windows.
+	windows := map[string]string{"root": "b", "a": "c"}
+
+	rpt := fix.rootTree()
+	poe := rpt.Packages["root"]
+	poe.P.ConstrainedImports = map[string][]string{"windows": {"b"}}
+	rpt.Packages["root"] = poe
+
+	for _, targets := range [][]pkgtree.Target{
+		nil,
+		{{GOOS: "linux", GOARCH: "amd64"}, {GOOS: "linux", GOARCH: "arm64"}},
+		{{GOOS: "windows", GOARCH: "amd64"}},
+	} {
+		params := SolveParameters{
+			RootDir:         string(fix.ds[0].n),
+			RootPackageTree: rpt,
+			Manifest:        fix.rootmanifest(),
+			ProjectAnalyzer: naiveAnalyzer{},
+			Targets:         targets,
+		}
+
+		s, err := Prepare(params, windowsSM{newbmSM(fix), windows})
+		if err != nil {
+			t.Fatalf("unexpected error while preparing solver: %s", err)
+		}
+		soln, err := s.Solve()
+		if err != nil {
+			t.Fatalf("unexpected error while solving: %s", err)
+		}
+
+		var got []string
+		for _, lp := range soln.Projects() {
+			got = append(got, string(lp.Ident().ProjectRoot))
+		}
+		sort.Strings(got)
+		want := []string{"a", "b", "c"}
+		if len(targets) > 0 && targets[0].GOOS == "linux" {
+			want = []string{"a"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("(%v) expected projects %v, got %v", targets, want, got)
+		}
+	}
+}
+
+func TestSolveGoVersion(t *testing.T) {
+	internal.IsStdLib = doIsStdLib
+	defer overrideIsStdLib()
+
+	fix := bimodalFixture{
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0"),
+				pkg("root", "company/lib", "context"),
+			),
+			dsp(mkDepspec("company/lib 1.0.0"), pkg("company/lib", "math/bits")),
+			dsp(mkDepspec("math/bits 1.0.0"), pkg("math/bits")),
+			dsp(mkDepspec("context 1.0.0"), pkg("context")),
+		},
+	}
+
+	for _, f := range []struct {
+		gover string
+		want  []string
+	}{
+		// By default, every dot-less import is taken to be in the standard
+		// library.
+		{"", nil},
+		{pkgtree.StdLibHeuristic, nil},
+		// Given a Go version, a dot-less import is only in the standard
+		// library if it's listed.
+		{"go1.9", []string{"company/lib"}},
+		// Before go1.9, math/bits was not in the standard library.
+		{"go1.8", []string{"company/lib", "math/bits"}},
+		// Nor, before go1.7, was context; the root's import of it is followed,
+		// too.
+		{"go1.6", []string{"company/lib", "context", "math/bits"}},
+	} {
+		params := SolveParameters{
+			RootDir:         string(fix.ds[0].n),
+			RootPackageTree: fix.rootTree(),
+			Manifest:        fix.rootmanifest(),
+			ProjectAnalyzer: naiveAnalyzer{},
+			GoVersion:       f.gover,
+		}
+
+		s, err := Prepare(params, newbmSM(fix))
+		if err != nil {
+			t.Fatalf("unexpected error while preparing solver: %s", err)
+		}
+		soln, err := s.Solve()
+		if err != nil {
+			t.Fatalf("(%q) unexpected error while solving: %s", f.gover, err)
+		}
+
+		var got []string
+		for _, lp := range soln.Projects() {
+			got = append(got, string(lp.Ident().ProjectRoot))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, f.want) {
+			t.Errorf("(%q) expected projects %v, got %v", f.gover, f.want, got)
+		}
+	}
+}
+
+func TestSolveAppengineImports(t *testing.T) {
+	internal.IsStdLib = doIsStdLib
+	defer overrideIsStdLib()
+
+	// The root imports the App Engine SDK's packages, which no source can
+	// provide, from a file built only with the appengine tag. Unless a Go
+	// version is given, they're taken to be in the standard library.
+	ptree, err := pkgtree.ListPackages(filepath.Join("_testdata", "src", "gae"), "gae")
+	if err != nil {
+		t.Fatalf("unexpected error listing packages: %s", err)
+	}
+	ds := []depspec{mkDepspec("gae 0.0.0")}
+
+	for _, gover := range []string{"", pkgtree.StdLibHeuristic} {
+		params := SolveParameters{
+			RootDir:         "gae",
+			RootPackageTree: ptree,
+			Manifest:        simpleRootManifest{},
+			ProjectAnalyzer: naiveAnalyzer{},
+			GoVersion:       gover,
+		}
+
+		s, err := Prepare(params, newdepspecSM(ds, nil))
+		if err != nil {
+			t.Fatalf("(%q) unexpected error while preparing solver: %s", gover, err)
+		}
+		soln, err := s.Solve()
+		if err != nil {
+			t.Fatalf("(%q) unexpected error while solving: %s", gover, err)
+		}
+		if len(soln.Projects()) != 0 {
+			t.Errorf("(%q) expected no projects, got %v", gover, soln.Projects())
+		}
+	}
+}
+
+// manifestLogSM records the atoms whose manifests are fetched.
+type manifestLogSM struct {
+	*depspecSourceManager
+	fetched map[string]bool
+}
+
+func (sm *manifestLogSM) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	sm.fetched[fmt.Sprintf("%s %s", id.ProjectRoot, v)] = true
+	return sm.depspecSourceManager.GetManifestAndLockContext(ctx, id, v, an)
+}
+
+func TestSolveGoVersionSkipsDisallowedManifests(t *testing.T) {
+	fix := basicFixtures["minimum go version excludes every version"]
+	sm := &manifestLogSM{
+		depspecSourceManager: newdepspecSM(fix.ds, nil),
+		fetched:              make(map[string]bool),
+	}
+
+	params := SolveParameters{
+		RootDir:             string(fix.ds[0].n),
+		RootPackageTree:     fix.rootTree(),
+		Manifest:            fix.rootmanifest(),
+		ProjectAnalyzer:     naiveAnalyzer{},
+		GoVersion:           fix.gover,
+		PrefetchConcurrency: -1,
+	}
+
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+	if _, err = s.Solve(); err == nil {
+		t.Fatal("expected solving to fail")
+	}
+
+	// The root's constraint rules out foo 2.0.0, so there's no need to look
+	// at its manifest for a minimum Go version.
+	if sm.fetched["foo 2.0.0"] {
+		t.Error("fetched the manifest of a version the constraints disallow")
+	}
+	if !sm.fetched["foo 1.0.0"] {
+		t.Error("expected the manifest of an allowed version to be fetched")
+	}
+}
+
+func TestSolveBudgetExceeded(t *testing.T) {
+	table := []struct {
+		name        string
+		fix         basicFixture
+		timeout     time.Duration
+		maxAttempts int
+		err         error
+	}{
+		{
+			name:        "attempts",
+			fix:         basicFixtures["mutual downgrading"],
+			maxAttempts: 1,
+			err:         ErrAttemptsExceeded,
+		},
+		{
+			name:        "mvs attempts",
+			fix:         basicFixtures["mvs follows requirements of raised selections"],
+			maxAttempts: 1,
+			err:         ErrAttemptsExceeded,
+		},
+		{
+			name:    "timeout",
+			fix:     basicFixtures["mutual downgrading"],
+			timeout: time.Nanosecond,
+			err:     context.DeadlineExceeded,
+		},
+	}
+
+	for _, c := range table {
+		params := SolveParameters{
+			RootDir:         string(c.fix.ds[0].n),
+			RootPackageTree: c.fix.rootTree(),
+			Manifest:        c.fix.rootmanifest(),
+			ProjectAnalyzer: naiveAnalyzer{},
+			MVS:             c.fix.mvs,
+			Timeout:         c.timeout,
+			MaxAttempts:     c.maxAttempts,
+		}
+
+		s, err := Prepare(params, newdepspecSM(c.fix.ds, nil))
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error while preparing solver: %s", c.name, err)
+		}
+
+		_, err = s.Solve()
+		berr, ok := err.(*SolveBudgetError)
+		if !ok {
+			t.Errorf("(%s) Expected *SolveBudgetError, got %T: %v", c.name, err, err)
+			continue
+		}
+		if berr.Err != c.err {
+			t.Errorf("(%s) Expected wrapped error to be %q, got %q", c.name, c.err, berr.Err)
+		}
+		if c.maxAttempts == 0 {
+			continue
+		}
+
+		if berr.Attempts <= c.maxAttempts {
+			t.Errorf("(%s) Expected more than %v attempts, got %v", c.name, c.maxAttempts, berr.Attempts)
+		}
+		if len(berr.Partial) == 0 {
+			t.Errorf("(%s) Expected a partial selection", c.name)
+		}
+		for k := 1; k < len(berr.Partial); k++ {
+			if berr.Partial[k-1].Ident().ProjectRoot >= berr.Partial[k].Ident().ProjectRoot {
+				t.Errorf("(%s) Expected partial selection to be sorted by root, got %v", c.name, berr.Partial)
+				break
+			}
+		}
+		if !c.fix.mvs && len(berr.Failures) == 0 {
+			t.Errorf("(%s) Expected the failures that caused backtracking", c.name)
+		}
+	}
+
+	// A solve that stays within its budget is unaffected by it.
+	fix := basicFixtures["simple dependency tree"]
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		MaxAttempts:     1,
+	}
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while preparing solver: %s", err)
+	}
+	if _, err = s.Solve(); err != nil {
+		t.Errorf("Unexpected error from solve within budget: %s", err)
+	}
+}
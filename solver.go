@@ -2,10 +2,12 @@ package gps
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/armon/go-radix"
 	"github.com/sdboyer/gps/internal"
@@ -66,6 +68,46 @@ type SolveParameters struct {
 	// element must be present in the Packages map.
 	RootPackageTree pkgtree.PackageTree
 
+	// Workspace lists further root projects to solve together with the root
+	// project, into a single Lock - for example, the other services in a
+	// monorepo. Each contributes its imports and constraints, and they treat
+	// each other as local: imports among them are not solved for, and any
+	// constraints they place on each other are disregarded.
+	//
+	// Constraints from different roots on the same project are intersected,
+	// and overrides must agree. Each root's ignored packages apply to its own
+	// packages; elsewhere, a package is only ignored if every root ignores it.
+	// The vendor directory, if any, is the one under RootDir.
+	Workspace []WorkspaceRoot
+
+	// Targets, if non-empty, lists the platforms and build tags for which the
+	// root project is built. Imports that appear only in files whose build
+	// constraints - //go:build or "// +build" lines, or GOOS and GOARCH
+	// filename suffixes - none of the targets satisfy are disregarded, in the
+	// root project and in its dependencies alike, so that a project only
+	// reachable through, say, Windows-only files is not brought into a solve
+	// for Linux.
+	//
+	// Targets are part of the hashed inputs, so a lock produced for one set
+	// of targets is not in sync with a solve for another.
+	//
+	// If empty, all imports are followed, regardless of build constraints.
+	Targets []pkgtree.Target
+
+	// GoVersion is the Go release, such as "go1.9", whose standard library
+	// packages are excluded from solving. Imports of any other packages,
+	// including those lacking a dot in their first path element, are solved
+	// for.
+	//
+	// If given, it is also the release the solution must build with: versions
+	// of dependencies whose manifests implement GoVersionManifest, and declare
+	// a newer minimum Go version, are rejected.
+	//
+	// If empty, or pkgtree.StdLibHeuristic, every import whose first path
+	// element lacks a dot is treated as being in the standard library, and no
+	// minimum Go versions are enforced.
+	GoVersion string
+
 	// The root manifest. This contains all the dependency constraints
 	// associated with normal Manifests, as well as the particular controls
 	// afforded only to the root project.
@@ -101,6 +143,77 @@ type SolveParameters struct {
 	// typical case.
 	Downgrade bool
 
+	// MVS indicates whether the solver will perform minimal version selection,
+	// rather than searching for the newest versions that satisfy all
+	// constraints.
+	//
+	// Under minimal version selection, each project is selected at the highest
+	// of the minimal versions admitted by each of the constraints on it. The
+	// result changes only when constraints change, not when new versions are
+	// released, so the root lock, ToChange, ChangeAll and Downgrade are all
+	// ignored.
+	MVS bool
+
+	// VersionPreference, if provided, determines the order in which the
+	// candidate versions of each project are tried, in place of the default
+	// order given by SortForUpgrade or SortForDowngrade. Versions from the
+	// root lock, or preferred by a dependency's lock, are still tried first.
+	//
+	// It has no effect on minimal version selection.
+	VersionPreference VersionPreference
+
+	// Deny lists rules for versions that must not be selected, such as
+	// releases with known vulnerabilities. Denied versions are skipped, but
+	// unlike with an override, the affected project is not pinned; any other
+	// version may be selected.
+	Deny []DenyRule
+
+	// Retain, if set, makes the returned Solution a RetainedSolution, keeping
+	// the dependency graph among the selected projects, what was learned
+	// about why each is at its version, and the manifests, locks and package
+	// trees retrieved for them. This allows the Solution to explain itself,
+	// and to be passed as Previous, at the cost of holding on to all of that
+	// for as long as the Solution is kept.
+	Retain bool
+
+	// Previous, if non-nil, is a Solution from an earlier solving run for the
+	// same root project, which the run should build on rather than solving
+	// from scratch. It must have been returned by a Solver with Retain set,
+	// and it is used as the root lock in place of Lock.
+	//
+	// The root's constraints, overrides, deny rules and imports are compared
+	// with those Previous was produced from. The projects affected by a
+	// change, along with those in ToChange, and every project reachable from
+	// any of them through Previous's dependencies, are solved afresh, as if
+	// they were in ToChange. All the other projects are kept at the versions
+	// in Previous unless a conflict forces them to move, and the manifests,
+	// locks and package trees already retrieved for them are reused without
+	// consulting the SourceManager.
+	Previous Solution
+
+	// Timeout, if positive, bounds how long a solving run may take. When it
+	// elapses, the run stops and returns a *SolveBudgetError describing how
+	// far it got.
+	Timeout time.Duration
+
+	// MaxAttempts, if positive, bounds the number of attempts a solving run
+	// may make - that is, the number of times it may backtrack, or under
+	// minimal version selection, the number of passes it may make over the
+	// dependency graph. When it is exceeded, the run stops and returns a
+	// *SolveBudgetError describing how far it got.
+	MaxAttempts int
+
+	// PrefetchConcurrency bounds how many speculative requests the solver may
+	// make of the SourceManager at once. As it discovers dependencies, the
+	// solver fetches, in the background, the version lists, package trees,
+	// manifests and locks it is most likely to need next, so that network
+	// round trips overlap rather than happening one at a time. This only
+	// warms the SourceManager's caches; it never changes the solution.
+	//
+	// If zero, DefaultPrefetchConcurrency is used. If negative, no prefetching
+	// is done.
+	PrefetchConcurrency int
+
 	// Trace controls whether the solver will generate informative trace output
 	// as it moves through the solving process.
 	Trace bool
@@ -113,6 +226,10 @@ type SolveParameters struct {
 // solver is a CDCL-style constraint solver with satisfiability conditions
 // hardcoded to the needs of the Go package management problem space.
 type solver struct {
+	// The context for the current solve run. Cancellation of this context
+	// stops the run, and is also passed along to the SourceManager.
+	ctx context.Context
+
 	// The current number of attempts made over the course of this solve. This
 	// number increments each time the algorithm completes a backtrack and
 	// starts moving forward again.
@@ -161,6 +278,38 @@ type solver struct {
 	// Contains data and constraining information from the root project
 	rd rootdata
 
+	// Nogoods derived over the course of the solve run, used to avoid
+	// searching again through parts of the search space already known to
+	// contain no solution.
+	ngs nogoodStore
+
+	// The nogood explaining the failure currently being backtracked from, if
+	// known. It determines how far backtracking jumps.
+	conflict *nogood
+
+	// Whether to perform minimal version selection instead of searching.
+	mvs bool
+
+	// Whether to prefer older versions over newer ones.
+	downgrade bool
+
+	// The order in which to try candidate versions, if not the default.
+	pref VersionPreference
+
+	// Whether to keep the detail of the run in its solution.
+	retain bool
+
+	// The limits on the solve run, and how far it has got within them.
+	bgt budget
+
+	// Manifests, locks and package trees retrieved for immutable atoms, by
+	// atomKey, including any reused from a previous solution.
+	atoms map[string]atomData
+
+	// The maximum number of speculative fetches the bridge may make at once.
+	// Zero means none.
+	prefetch int
+
 	// metrics for the current solve run.
 	mtr *metrics
 }
@@ -245,8 +394,32 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 		return rootdata{}, badOptsFailure(fmt.Sprintf("An override was declared for %s, but without any non-zero properties", eovr[0]))
 	}
 
+	for _, t := range params.Targets {
+		if t.GOOS == "" || t.GOARCH == "" {
+			return rootdata{}, badOptsFailure(fmt.Sprintf("targets must specify both GOOS and GOARCH, got %q", t))
+		}
+	}
+	rd.targets = params.Targets
+
+	var err error
+	if rd.gover, err = internal.ParseGoVersion(params.GoVersion); err != nil {
+		return rootdata{}, badOptsFailure(err.Error())
+	}
+	rd.gominor = -1
+	if rd.gover != internal.StdLibHeuristic {
+		rd.gominor, _ = internal.GoMinor(rd.gover)
+	}
+
+	rd.deny, err = prepDenyRules(params.Deny)
+	if err != nil {
+		return rootdata{}, err
+	}
+
 	// Prep safe, normalized versions of root manifest and lock data
 	rd.rm = prepManifest(params.Manifest)
+	if err = rd.prepWorkspace(params); err != nil {
+		return rootdata{}, err
+	}
 
 	if params.Lock != nil {
 		for _, lp := range params.Lock.Projects() {
@@ -281,24 +454,58 @@ func Prepare(params SolveParameters, sm SourceManager) (Solver, error) {
 		return nil, badOptsFailure("trace requested, but no logger provided")
 	}
 
+	var prev retainedSolution
+	if params.Previous != nil {
+		var ok bool
+		if prev, ok = params.Previous.(retainedSolution); !ok {
+			return nil, badOptsFailure(fmt.Sprintf("previous solution must have been returned by a Solver with Retain set, got %T", params.Previous))
+		}
+		params.Lock = prev
+	}
+
 	rd, err := params.toRootdata()
 	if err != nil {
 		return nil, err
 	}
 
 	s := &solver{
-		tl: params.TraceLogger,
-		rd: rd,
+		ctx:       context.Background(),
+		tl:        params.TraceLogger,
+		rd:        rd,
+		mvs:       params.MVS,
+		downgrade: params.Downgrade,
+		pref:      params.VersionPreference,
+		retain:    params.Retain,
+		bgt: budget{
+			timeout:     params.Timeout,
+			maxAttempts: params.MaxAttempts,
+		},
+		atoms: make(map[string]atomData),
+	}
+	if params.Previous != nil {
+		s.buildFrom(prev)
+	}
+
+	switch {
+	case params.PrefetchConcurrency > 0:
+		s.prefetch = params.PrefetchConcurrency
+	case params.PrefetchConcurrency == 0:
+		s.prefetch = DefaultPrefetchConcurrency
 	}
 
 	// Set up the bridge and ensure the root dir is in good, working order
 	// before doing anything else. (This call is stubbed out in tests, via
 	// overriding mkBridge(), so we can run with virtual RootDir.)
-	s.b = mkBridge(s, sm, params.Downgrade)
+	s.b = mkBridge(s, toContextSourceManager(sm), params.Downgrade)
 	err = s.b.verifyRootDir(params.RootDir)
 	if err != nil {
 		return nil, err
 	}
+	for _, w := range params.Workspace {
+		if err = s.b.verifyRootDir(w.Dir); err != nil {
+			return nil, err
+		}
+	}
 	s.vUnify = versionUnifier{
 		b: s.b,
 	}
@@ -335,6 +542,11 @@ type Solver interface {
 	// Solve initiates a solving run. It will either complete successfully with
 	// a Solution, or fail with an informative error.
 	Solve() (Solution, error)
+
+	// SolveContext is the same as Solve, but the solving run is bounded by the
+	// provided context. If the context is canceled or its deadline passes, the
+	// run stops promptly and returns a *SolveCanceledError.
+	SolveContext(context.Context) (Solution, error)
 }
 
 // Solve attempts to find a dependency solution for the given project, as
@@ -342,6 +554,31 @@ type Solver interface {
 //
 // This is the entry point to the main gps workhorse.
 func (s *solver) Solve() (Solution, error) {
+	return s.SolveContext(context.Background())
+}
+
+// SolveContext attempts to find a dependency solution for the given project,
+// stopping early if the provided context is canceled.
+//
+// If the run is canceled, or exceeds its budget, SolveContext returns without
+// waiting for any speculative fetches still in flight to finish.
+func (s *solver) SolveContext(ctx context.Context) (Solution, error) {
+	parent := ctx
+	ctx, stop := s.bgt.start(ctx)
+	s.ctx = ctx
+
+	// Speculative fetches are abandoned once the run is over, but let them
+	// wind down before returning - unless the run was canceled, as fetches
+	// through a SourceManager that can't itself be canceled would hold up the
+	// return until they finished.
+	defer func() {
+		canceled := ctx.Err() != nil
+		stop()
+		if !canceled {
+			s.b.waitPrefetch()
+		}
+	}()
+
 	// Set up a metrics object
 	s.mtr = newMetrics()
 	s.vUnify.mtr = s.mtr
@@ -352,10 +589,34 @@ func (s *solver) Solve() (Solution, error) {
 		return nil, err
 	}
 
-	all, err := s.solve()
+	var all map[atom]map[string]struct{}
+	if s.mvs {
+		all, err = s.solveMVS()
+	} else {
+		all, err = s.solve()
+	}
+	if err != nil && ctx.Err() != nil && parent.Err() == nil {
+		// The run was stopped by its own budget, rather than by the caller.
+		err = s.bgt.exceeded(s.attempts)
+	} else if err != nil && ctx.Err() != nil {
+		// Whatever failure was reported may well have been induced by the
+		// cancellation; report the cancellation instead.
+		err = &SolveCanceledError{
+			Err:      ctx.Err(),
+			Attempts: s.attempts,
+		}
+	} else if err != nil && s.conflict != nil && len(s.conflict.terms) == 0 {
+		// The search ended having learned that no solution is possible at
+		// all, so explain how that was determined.
+		err = &UnsolvableError{
+			Err:        err,
+			Derivation: s.conflict.incompatibility(make(map[*nogood]*Incompatibility)),
+		}
+	}
 
 	s.mtr.pop()
 	var soln solution
+	var rsoln retainedSolution
 	if err == nil {
 		soln = solution{
 			att: s.attempts,
@@ -371,18 +632,49 @@ func (s *solver) Solve() (Solution, error) {
 			k++
 		}
 	}
+	if err == nil && s.retain {
+		rsoln, err = s.retainSolution(soln)
+	}
 
 	s.traceFinish(soln, err)
 	if s.tl != nil {
 		s.mtr.dump(s.tl)
 	}
+	if err == nil && s.retain {
+		return rsoln, nil
+	}
 	return soln, err
 }
 
+// retainSolution gathers the detail about the completed run that's kept
+// alongside its solution when SolveParameters.Retain is set.
+func (s *solver) retainSolution(soln solution) (retainedSolution, error) {
+	g, err := s.buildDepGraph()
+	if err != nil {
+		return retainedSolution{}, err
+	}
+
+	return retainedSolution{
+		solution: soln,
+		g:        g,
+		held:     s.buildHeldData(g),
+		mode: heldSolveMode{
+			mvs:       s.mvs,
+			downgrade: s.downgrade,
+			pref:      s.pref != nil,
+		},
+		rec: s.recordSolve(soln.p),
+	}, nil
+}
+
 // solve is the top-level loop for the solving process.
 func (s *solver) solve() (map[atom]map[string]struct{}, error) {
 	// Main solving loop
 	for {
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		bmi, has := s.nextUnselected()
 
 		if !has {
@@ -404,6 +696,7 @@ func (s *solver) solve() (map[atom]map[string]struct{}, error) {
 			queue, err := s.createVersionQueue(bmi)
 			if err != nil {
 				// Err means a failure somewhere down the line; try backtracking.
+				s.bgt.recordFailure(err)
 				s.traceStartBacktrack(bmi, err, false)
 				s.mtr.pop()
 				if s.backtrack() {
@@ -452,6 +745,8 @@ func (s *solver) solve() (map[atom]map[string]struct{}, error) {
 			err := s.check(nawp, true)
 			if err != nil {
 				// Err means a failure somewhere down the line; try backtracking.
+				s.conflict = s.learn(nawp, err)
+				s.bgt.recordFailure(err)
 				s.traceStartBacktrack(bmi, err, true)
 				if s.backtrack() {
 					// backtracking succeeded, move to the next unselected id
@@ -470,22 +765,7 @@ func (s *solver) solve() (map[atom]map[string]struct{}, error) {
 
 	// Getting this far means we successfully found a solution. Combine the
 	// selected projects and packages.
-	projs := make(map[atom]map[string]struct{})
-
-	// Skip the first project. It's always the root, and that shouldn't be
-	// included in results.
-	for _, sel := range s.sel.projects[1:] {
-		pm, exists := projs[sel.a.a]
-		if !exists {
-			pm = make(map[string]struct{})
-			projs[sel.a.a] = pm
-		}
-
-		for _, path := range sel.a.pl {
-			pm[path] = struct{}{}
-		}
-	}
-	return projs, nil
+	return s.sel.combined(), nil
 }
 
 // selectRoot is a specialized selectAtom, used solely to initially
@@ -505,12 +785,9 @@ func (s *solver) selectRoot() error {
 	}
 
 	for _, dep := range deps {
-		// If we have no lock, or if this dep isn't in the lock, then prefetch
-		// it. See longer explanation in selectAtom() for how we benefit from
-		// parallelism here.
-		if s.rd.needVersionsFor(dep.Ident.ProjectRoot) {
-			go s.b.SyncSourceFor(dep.Ident)
-		}
+		// Prefetch what we'll likely need for the dep. See longer explanation
+		// in selectAtom() for how we benefit from parallelism here.
+		s.prefetchDep(dep, nil)
 
 		s.sel.pushDep(dependency{depender: awp.a, dep: dep})
 		// Add all to unselected queue
@@ -541,7 +818,7 @@ func (s *solver) getImportsAndConstraintsOf(a atomWithPackages) ([]string, []com
 		return nil, nil, err
 	}
 
-	rm, em := ptree.ToReachMap(true, false, true, s.rd.ig)
+	rm, em := ptree.ToReachMapFor(true, false, true, s.rd.ig, s.rd.targets)
 	// Use maps to dedupe the unique internal and external packages.
 	exmap, inmap := make(map[string]struct{}), make(map[string]struct{})
 
@@ -617,7 +894,7 @@ func (s *solver) intersectConstraintsWithImports(deps []workingConstraint, reach
 	dmap := make(map[ProjectRoot]completeDep)
 	for _, rp := range reach {
 		// If it's a stdlib-shaped package, skip it.
-		if internal.IsStdLib(rp) {
+		if s.rd.isStdLib(rp) {
 			continue
 		}
 
@@ -675,7 +952,7 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 	id := bmi.id
 	// If on the root package, there's no queue to make
 	if s.rd.isRoot(id.ProjectRoot) {
-		return newVersionQueue(id, nil, nil, s.b)
+		return newVersionQueue(id, nil, nil, s.b, nil, nil)
 	}
 
 	exists, err := s.b.SourceExists(id)
@@ -751,7 +1028,7 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 		prefv = bmi.prefv
 	}
 
-	q, err := newVersionQueue(id, lockv, prefv, s.b)
+	q, err := newVersionQueue(id, lockv, prefv, s.b, s.pref, s.sel.getConstraint(id))
 	if err != nil {
 		// TODO(sdboyer) this particular err case needs to be improved to be ONLY for cases
 		// where there's absolutely nothing findable about a given project name
@@ -803,21 +1080,38 @@ func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 	faillen := len(q.fails)
 
 	for {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
 		cur := q.current()
 		s.traceInfo("try %s@%s", q.id.errString(), cur)
-		err := s.check(atomWithPackages{
+		awp := atomWithPackages{
 			a: atom{
 				id: q.id,
 				v:  cur,
 			},
 			pl: pl,
-		}, false)
-		if err == nil {
-			// we have a good version, can return safely
-			return nil
 		}
 
-		if q.advance(err) != nil {
+		var err error
+		reason := s.nogoodFor(awp)
+		if reason != nil {
+			// A nogood learned earlier already rules this version out; skip
+			// the checks, and the search beneath it that would be futile.
+			s.traceInfo("skip %s@%s, excluded by a learned nogood", q.id.errString(), cur)
+			s.blame(reason)
+			err = reason.cause
+		} else {
+			err = s.check(awp, false)
+			if err == nil {
+				// we have a good version, can return safely
+				return nil
+			}
+			reason = s.learn(awp, err)
+		}
+
+		if q.advanceWith(err, reason) != nil {
 			// Error on advance, have to bail out
 			break
 		}
@@ -831,10 +1125,12 @@ func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 
 	// Return a compound error of all the new errors encountered during this
 	// attempt to find a new, valid version
-	return &noVersionError{
+	err := &noVersionError{
 		pn:    q.id,
 		fails: q.fails[faillen:],
 	}
+	s.conflict = s.resolve(q, err)
+	return err
 }
 
 // getLockVersionIfValid finds an atom for the given ProjectIdentifier from the
@@ -924,6 +1220,22 @@ func (s *solver) backtrack() bool {
 
 	s.mtr.push("backtrack")
 	for {
+		if s.ctx.Err() != nil {
+			// Canceled; there's no point in continuing to search.
+			s.mtr.pop()
+			return false
+		}
+
+		if s.conflict != nil {
+			// The cause of the failure is known precisely, so rather than
+			// relying on the failure marks accumulated along the way, jump
+			// straight back to the most recent selection responsible for it.
+			for _, vq := range s.vqs {
+				vq.failed = false
+			}
+			s.blame(s.conflict)
+		}
+
 		for {
 			if len(s.vqs) == 0 {
 				// no more versions, nowhere further to backtrack
@@ -959,7 +1271,8 @@ func (s *solver) backtrack() bool {
 
 		// Advance the queue past the current version, which we know is bad
 		// TODO(sdboyer) is it feasible to make available the failure reason here?
-		if q.advance(nil) == nil && !q.isExhausted() {
+		err := q.advanceWith(nil, s.conflict)
+		if err == nil && !q.isExhausted() {
 			// Search for another acceptable version of this failed dep in its queue
 			s.traceCheckQueue(q, awp.bmi(), true, 0)
 			if s.findValidVersion(q, awp.pl) == nil {
@@ -971,6 +1284,12 @@ func (s *solver) backtrack() bool {
 				s.selectAtom(awp, false)
 				break
 			}
+		} else if err == nil {
+			// The queue ran out without another version to check, so derive
+			// the new conflict here instead of in findValidVersion().
+			s.conflict = s.resolve(q, &noVersionError{pn: q.id, fails: q.fails})
+		} else {
+			s.conflict = nil
 		}
 
 		s.traceBacktrack(awp.bmi(), false)
@@ -987,7 +1306,9 @@ func (s *solver) backtrack() bool {
 	if len(s.vqs) == 0 {
 		return false
 	}
+	s.conflict = nil
 	s.attempts++
+	s.bgt.countAttempt(s.attempts)
 	return true
 }
 
@@ -1108,6 +1429,7 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) {
 	// selection stack
 	a.pl = pl
 	s.sel.pushSelection(a, pkgonly)
+	s.bgt.recordSelection(s.sel)
 
 	// If this atom has a lock, pull it out so that we can potentially inject
 	// preferred versions into any bmis we enqueue
@@ -1131,10 +1453,10 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) {
 		if s.rd.isRoot(dep.Ident.ProjectRoot) {
 			continue
 		}
-		// If this is dep isn't in the lock, do some prefetching. (If it is, we
-		// might be able to get away with zero network activity for it, so don't
-		// prefetch). This provides an opportunity for some parallelism wins, on
-		// two fronts:
+		// Prefetch what we'll likely need to select the dep: its locked or
+		// preferred version, and if it isn't locked by the root, its versions
+		// and the head of its version queue. This provides an opportunity for
+		// some parallelism wins, on two fronts:
 		//
 		// 1. Because this loop may have multiple deps in it, we could end up
 		// simultaneously fetching both in the background while solving proceeds
@@ -1144,9 +1466,7 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) {
 		// few microseconds before blocking later. Best case, the dep doesn't
 		// come up next, but some other dep comes up that wasn't prefetched, and
 		// both fetches proceed in parallel.
-		if s.rd.needVersionsFor(dep.Ident.ProjectRoot) {
-			go s.b.SyncSourceFor(dep.Ident)
-		}
+		s.prefetchDep(dep, lmap[dep.Ident])
 
 		s.sel.pushDep(dependency{depender: a.a, dep: dep})
 		// Go through all the packages introduced on this dep, selecting only
@@ -1177,6 +1497,21 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) {
 	s.mtr.pop()
 }
 
+// prefetchDep starts fetching, in the background, the information the solver
+// is most likely to need when it comes to select the given dependency.
+//
+// If the dependency is locked by the root and not being changed, that is its
+// locked version. Otherwise, it's the version preferred by the depender's
+// lock, if any, along with the version list and the first version in it that
+// the dependency's constraint allows.
+func (s *solver) prefetchDep(dep completeDep, prefv Version) {
+	if !s.rd.needVersionsFor(dep.Ident.ProjectRoot) {
+		s.b.prefetch(dep.Ident, nil, s.rd.rlm[dep.Ident.ProjectRoot].Version())
+		return
+	}
+	s.b.prefetch(dep.Ident, dep.Constraint, prefv)
+}
+
 func (s *solver) unselectLast() (atomWithPackages, bool) {
 	s.mtr.push("unselect")
 	awp, first := s.sel.popSelection()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 
 	"github.com/sdboyer/gps/pkgtree"
@@ -44,14 +45,15 @@ type sourceCoordinator struct {
 	psrcmut    sync.Mutex // guards protoSrcs map
 	protoSrcs  map[string][]srcReturnChans
 	deducer    deducer
-	cachedir   string
+	layers     cacheLayers
+	schemes    *schemePolicy // filters and orders candidate sources; may be nil
 }
 
-func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string) *sourceCoordinator {
+func newSourceCoordinator(superv *supervisor, deducer deducer, cachedirs []string) *sourceCoordinator {
 	return &sourceCoordinator{
 		supervisor: superv,
 		deducer:    deducer,
-		cachedir:   cachedir,
+		layers:     cacheLayers(cachedirs),
 		srcs:       make(map[string]*sourceGateway),
 		nameToURL:  make(map[string]string),
 		protoSrcs:  make(map[string][]srcReturnChans),
@@ -146,7 +148,13 @@ func (sc *sourceCoordinator) setUpSourceGateway(ctx context.Context, normalizedN
 	}
 	sc.srcmut.RUnlock()
 
-	srcGate = newSourceGateway(pd.mb, sc.supervisor, sc.cachedir)
+	mb, err := sc.schemes.apply(pd.mb)
+	if err != nil {
+		doReturn(nil, err)
+		return
+	}
+
+	srcGate = newSourceGateway(mb, sc.supervisor, sc.layers)
 
 	// The normalized name is usually different from the source URL- e.g.
 	// github.com/sdboyer/gps vs. https://github.com/sdboyer/gps. But it's
@@ -185,6 +193,7 @@ func (sc *sourceCoordinator) setUpSourceGateway(ctx context.Context, normalizedN
 // and caching them as needed.
 type sourceGateway struct {
 	cachedir string
+	layers   cacheLayers
 	maybe    maybeSource
 	srcState sourceState
 	src      source
@@ -193,10 +202,11 @@ type sourceGateway struct {
 	suprvsr  *supervisor
 }
 
-func newSourceGateway(maybe maybeSource, superv *supervisor, cachedir string) *sourceGateway {
+func newSourceGateway(maybe maybeSource, superv *supervisor, layers cacheLayers) *sourceGateway {
 	sg := &sourceGateway{
 		maybe:    maybe,
-		cachedir: cachedir,
+		cachedir: layers.top(),
+		layers:   layers,
 		suprvsr:  superv,
 	}
 	sg.cache = sg.createSingleSourceCache()
@@ -250,6 +260,12 @@ func (sg *sourceGateway) exportVersionTo(ctx context.Context, v Version, to stri
 		return err
 	}
 
+	unlock, err := sg.lockSource(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	return sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctExportTree, func(ctx context.Context) error {
 		return sg.src.exportRevisionTo(ctx, r, to)
 	})
@@ -274,6 +290,13 @@ func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 		return nil, nil, err
 	}
 
+	// Deriving a manifest and lock requires checking out the revision.
+	unlock, err := sg.lockSource(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
 	name, vers := an.Info()
 	label := fmt.Sprintf("%s:%s.%v", sg.src.upstreamURL(), name, vers)
 	err = sg.suprvsr.do(ctx, label, ctGetManifestAndLock, func(ctx context.Context) error {
@@ -309,6 +332,13 @@ func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Ver
 		return pkgtree.PackageTree{}, err
 	}
 
+	// Listing packages requires checking out the revision.
+	unlock, err := sg.lockSource(ctx)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	defer unlock()
+
 	label := fmt.Sprintf("%s:%s", pr, sg.src.upstreamURL())
 	err = sg.suprvsr.do(ctx, label, ctListPackages, func(ctx context.Context) error {
 		ptree, err = sg.src.listPackages(ctx, pr, r)
@@ -409,6 +439,43 @@ func (sg *sourceGateway) sourceURL(ctx context.Context) (string, error) {
 	return sg.src.upstreamURL(), nil
 }
 
+// lockSource acquires the exclusive, cross-process lock on the gateway's local
+// copy of its source. The returned func releases the lock.
+//
+// This must be held for any operation that modifies the local copy - fetches,
+// and checkouts for export or analysis - so that other processes sharing the
+// same cachedir don't observe or create an inconsistent state.
+//
+// Assumes sg.mu is held, and that the source has been set up.
+func (sg *sourceGateway) lockSource(ctx context.Context) (func(), error) {
+	return lockSourceURL(ctx, sg.cachedir, sg.src.upstreamURL())
+}
+
+// lockSourceURL acquires the exclusive, cross-process lock on the local copy,
+// within cachedir, of the source at the given upstream URL. The returned func
+// releases the lock.
+func lockSourceURL(ctx context.Context, cachedir, url string) (func(), error) {
+	cl := newCacheLock(filepath.Join(cachedir, "sources", sanitizer.Replace(url)+".lock"))
+	if err := cl.lock(ctx, true); err != nil {
+		cl.close()
+		return nil, err
+	}
+
+	return func() { cl.close() }, nil
+}
+
+// findInLowerLayers looks for an existing local copy of the gateway's source
+// in the read-only cache layers.
+//
+// Assumes the source has been set up.
+func (sg *sourceGateway) findInLowerLayers() (string, bool) {
+	rel, err := filepath.Rel(sg.cachedir, sg.src.localPath())
+	if err != nil {
+		return "", false
+	}
+	return sg.layers.findLower(rel)
+}
+
 // createSingleSourceCache creates a singleSourceCache instance for use by
 // the encapsulated source.
 func (sg *sourceGateway) createSingleSourceCache() singleSourceCache {
@@ -435,7 +502,7 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (errSt
 			case sourceIsSetUp:
 				sg.src, addlState, err = sg.maybe.try(ctx, sg.cachedir, sg.cache, sg.suprvsr)
 			case sourceExistsUpstream:
-				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourcePing, func(ctx context.Context) error {
+				err = sg.suprvsr.doHost(ctx, hostOf(sg.src.upstreamURL()), sg.src.sourceType(), ctSourcePing, func(ctx context.Context) error {
 					if !sg.src.existsUpstream(ctx) {
 						return fmt.Errorf("%s does not exist upstream", sg.src.upstreamURL())
 					}
@@ -443,19 +510,43 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (errSt
 				})
 			case sourceExistsLocally:
 				if !sg.src.existsLocally(ctx) {
-					err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
-						return sg.src.initLocal(ctx)
-					})
-
-					if err == nil {
-						addlState |= sourceHasLatestLocally
-					} else {
-						err = fmt.Errorf("%s does not exist in the local cache and fetching failed: %s", sg.src.upstreamURL(), err)
+					var unlock func()
+					unlock, err = sg.lockSource(ctx)
+					if err != nil {
+						return
 					}
+
+					// Another process may have created the local copy while
+					// we were waiting on the lock, so check again.
+					if !sg.src.existsLocally(ctx) {
+						if lpath, has := sg.findInLowerLayers(); has {
+							// A read-only layer has a copy; seed from it rather
+							// than going upstream. It may be stale, so don't
+							// mark the source as having the latest.
+							err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
+								return sg.src.initLocalFrom(ctx, lpath)
+							})
+
+							if err != nil {
+								err = fmt.Errorf("seeding %s from read-only cache at %s failed: %s", sg.src.upstreamURL(), lpath, err)
+							}
+						} else {
+							err = sg.suprvsr.doHost(ctx, hostOf(sg.src.upstreamURL()), sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
+								return sg.src.initLocal(ctx)
+							})
+
+							if err == nil {
+								addlState |= sourceHasLatestLocally
+							} else {
+								err = fmt.Errorf("%s does not exist in the local cache and fetching failed: %s", sg.src.upstreamURL(), err)
+							}
+						}
+					}
+					unlock()
 				}
 			case sourceHasLatestVersionList:
 				var pvl []PairedVersion
-				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctListVersions, func(ctx context.Context) error {
+				err = sg.suprvsr.doHost(ctx, hostOf(sg.src.upstreamURL()), sg.src.sourceType(), ctListVersions, func(ctx context.Context) error {
 					pvl, err = sg.src.listVersions(ctx)
 					return err
 				})
@@ -464,9 +555,16 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (errSt
 					sg.cache.storeVersionMap(pvl, true)
 				}
 			case sourceHasLatestLocally:
-				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceFetch, func(ctx context.Context) error {
+				var unlock func()
+				unlock, err = sg.lockSource(ctx)
+				if err != nil {
+					return
+				}
+
+				err = sg.suprvsr.doHost(ctx, hostOf(sg.src.upstreamURL()), sg.src.sourceType(), ctSourceFetch, func(ctx context.Context) error {
 					return sg.src.updateLocal(ctx)
 				})
+				unlock()
 			}
 
 			if err != nil {
@@ -491,7 +589,9 @@ type source interface {
 	existsLocally(context.Context) bool
 	existsUpstream(context.Context) bool
 	upstreamURL() string
+	localPath() string
 	initLocal(context.Context) error
+	initLocalFrom(context.Context, string) error
 	updateLocal(context.Context) error
 	listVersions(context.Context) ([]PairedVersion, error)
 	getManifestAndLock(context.Context, ProjectRoot, Revision, ProjectAnalyzer) (Manifest, Lock, error)
@@ -2,6 +2,7 @@ package gps
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -70,6 +71,116 @@ type SourceManager interface {
 	Release()
 }
 
+// A ContextSourceManager is a SourceManager whose methods have variants that
+// accept a context.Context, allowing callers to set deadlines on, or cancel,
+// individual calls.
+//
+// gps's SourceMgr implements this interface. When a SourceManager that does not
+// is passed to Prepare(), contexts are respected only between calls; a call
+// that has already begun will run to completion.
+type ContextSourceManager interface {
+	SourceManager
+
+	// SourceExistsContext is the context-taking variant of SourceExists.
+	SourceExistsContext(context.Context, ProjectIdentifier) (bool, error)
+
+	// SyncSourceForContext is the context-taking variant of SyncSourceFor.
+	SyncSourceForContext(context.Context, ProjectIdentifier) error
+
+	// ListVersionsContext is the context-taking variant of ListVersions.
+	ListVersionsContext(context.Context, ProjectIdentifier) ([]PairedVersion, error)
+
+	// RevisionPresentInContext is the context-taking variant of
+	// RevisionPresentIn.
+	RevisionPresentInContext(context.Context, ProjectIdentifier, Revision) (bool, error)
+
+	// ListPackagesContext is the context-taking variant of ListPackages.
+	ListPackagesContext(context.Context, ProjectIdentifier, Version) (pkgtree.PackageTree, error)
+
+	// GetManifestAndLockContext is the context-taking variant of
+	// GetManifestAndLock.
+	GetManifestAndLockContext(context.Context, ProjectIdentifier, Version, ProjectAnalyzer) (Manifest, Lock, error)
+
+	// ExportProjectContext is the context-taking variant of ExportProject.
+	ExportProjectContext(context.Context, ProjectIdentifier, Version, string) error
+
+	// DeduceProjectRootContext is the context-taking variant of
+	// DeduceProjectRoot.
+	DeduceProjectRootContext(context.Context, string) (ProjectRoot, error)
+}
+
+// toContextSourceManager returns the provided SourceManager as a
+// ContextSourceManager, adapting it if necessary.
+func toContextSourceManager(sm SourceManager) ContextSourceManager {
+	if csm, ok := sm.(ContextSourceManager); ok {
+		return csm
+	}
+	return ctxAdapter{SourceManager: sm}
+}
+
+// ctxAdapter adapts a plain SourceManager to the ContextSourceManager
+// interface. It checks the context before each call, but cannot interrupt a
+// call once it has started.
+type ctxAdapter struct {
+	SourceManager
+}
+
+func (a ctxAdapter) SourceExistsContext(ctx context.Context, id ProjectIdentifier) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.SourceExists(id)
+}
+
+func (a ctxAdapter) SyncSourceForContext(ctx context.Context, id ProjectIdentifier) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.SyncSourceFor(id)
+}
+
+func (a ctxAdapter) ListVersionsContext(ctx context.Context, id ProjectIdentifier) ([]PairedVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.ListVersions(id)
+}
+
+func (a ctxAdapter) RevisionPresentInContext(ctx context.Context, id ProjectIdentifier, r Revision) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.RevisionPresentIn(id, r)
+}
+
+func (a ctxAdapter) ListPackagesContext(ctx context.Context, id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	if err := ctx.Err(); err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return a.ListPackages(id, v)
+}
+
+func (a ctxAdapter) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return a.GetManifestAndLock(id, v, an)
+}
+
+func (a ctxAdapter) ExportProjectContext(ctx context.Context, id ProjectIdentifier, v Version, to string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.ExportProject(id, v, to)
+}
+
+func (a ctxAdapter) DeduceProjectRootContext(ctx context.Context, ip string) (ProjectRoot, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return a.DeduceProjectRoot(ip)
+}
+
 // A ProjectAnalyzer is responsible for analyzing a given path for Manifest and
 // Lock information. Tools relying on gps must implement one.
 type ProjectAnalyzer interface {
@@ -87,8 +198,9 @@ type ProjectAnalyzer interface {
 // There's no (planned) reason why it would need to be reimplemented by other
 // tools; control via dependency injection is intended to be sufficient.
 type SourceMgr struct {
-	cachedir    string                // path to root of cache dir
-	lf          *os.File              // handle for the sm lock file on disk
+	cachedir    string                // path to root of writable cache dir
+	layers      cacheLayers           // all cache dirs, writable one first
+	glock       *cacheLock            // shared lock on the cache as a whole
 	suprvsr     *supervisor           // subsystem that supervises running calls/io
 	cancelAll   context.CancelFunc    // cancel func to kill all running work
 	deduceCoord *deductionCoordinator // subsystem that manages import path deduction
@@ -105,12 +217,18 @@ func (smIsReleased) Error() string {
 	return "this SourceMgr has been released, its methods can no longer be called"
 }
 
-var _ SourceManager = &SourceMgr{}
+var _ ContextSourceManager = &SourceMgr{}
 
 // NewSourceManager produces an instance of gps's built-in SourceManager. It
 // takes a cache directory, where local instances of upstream sources are
 // stored.
 //
+// Multiple SourceMgrs, whether in the same process or different ones, may
+// safely share a cache directory. Each holds a shared OS-level lock on the
+// cache for its lifetime, and takes exclusive per-source locks while fetching
+// into or exporting from a source. These locks are released automatically if
+// a process dies, so a crash does not leave the cache unusable.
+//
 // The returned SourceManager aggressively caches information wherever possible.
 // If tools need to do preliminary work involving upstream repository analysis
 // prior to invoking a solve run, it is recommended that they create this
@@ -121,45 +239,105 @@ var _ SourceManager = &SourceMgr{}
 // bug!). It should be safe to reuse across concurrent solving runs, even on
 // unrelated projects.
 func NewSourceManager(cachedir string) (*SourceMgr, error) {
+	return NewLayeredSourceManager(cachedir)
+}
+
+// NewLayeredSourceManager produces an instance of gps's built-in SourceManager
+// that draws on an ordered list of cache directories.
+//
+// The first directory is the writable top layer, and behaves exactly like the
+// cachedir passed to NewSourceManager(); all fetches and new results are
+// written there. Any subsequent directories are read-only lower layers - for
+// example, a pre-warmed cache baked into a container image, or shared over
+// NFS. Before going upstream to create a local copy of a source, the
+// SourceMgr checks the lower layers, in order, and seeds the top layer from
+// the first copy it finds.
+//
+// Lower layers are never written to, and no locks are taken on them, so they
+// must not be modified while in use.
+func NewLayeredSourceManager(cachedirs ...string) (*SourceMgr, error) {
+	if len(cachedirs) == 0 {
+		return nil, errors.New("at least one cache directory must be provided")
+	}
+
+	for _, dir := range cachedirs[1:] {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("read-only cache layer %s is not a directory", dir)
+		}
+	}
+
+	cachedir := cachedirs[0]
 	err := os.MkdirAll(filepath.Join(cachedir, "sources"), 0777)
 	if err != nil {
 		return nil, err
 	}
 
+	// Take a shared lock on the cache as a whole. Any number of SourceMgrs,
+	// in any number of processes, may share a cache; access to individual
+	// sources is coordinated by finer-grained, per-source locks.
 	glpath := filepath.Join(cachedir, "sm.lock")
-	_, err = os.Stat(glpath)
-	if err == nil {
-		return nil, CouldNotCreateLockError{
-			Path: glpath,
-			Err:  fmt.Errorf("cache lock file %s exists - another process crashed or is still running?", glpath),
-		}
-	}
-
-	fi, err := os.OpenFile(glpath, os.O_CREATE|os.O_EXCL, 0600) // is 0600 sane for this purpose?
+	gl := newCacheLock(glpath)
+	err = gl.tryLock(false)
 	if err != nil {
+		gl.close()
 		return nil, CouldNotCreateLockError{
 			Path: glpath,
-			Err:  fmt.Errorf("err on attempting to create global cache lock: %s", err),
+			Err:  fmt.Errorf("err on attempting to acquire shared cache lock: %s", err),
 		}
 	}
 
 	ctx, cf := context.WithCancel(context.TODO())
 	superv := newSupervisor(ctx)
 	deducer := newDeductionCoordinator(superv)
+	var lowerds []string
+	for _, dir := range cachedirs[1:] {
+		lowerds = append(lowerds, filepath.Join(dir, "deductions"))
+	}
+	deducer.store = newDeductionStore(filepath.Join(cachedir, "deductions"), DefaultDeductionTTL, lowerds...)
+	schemes := &schemePolicy{}
+	deducer.schemes = schemes
+	srcCoord := newSourceCoordinator(superv, deducer, cachedirs)
+	srcCoord.schemes = schemes
 
 	sm := &SourceMgr{
 		cachedir:    cachedir,
-		lf:          fi,
+		layers:      cacheLayers(cachedirs),
+		glock:       gl,
 		suprvsr:     superv,
 		cancelAll:   cf,
 		deduceCoord: deducer,
-		srcCoord:    newSourceCoordinator(superv, deducer, cachedir),
+		srcCoord:    srcCoord,
 		qch:         make(chan struct{}),
 	}
 
 	return sm, nil
 }
 
+// cacheLayers is an ordered list of cache directories. The first is the
+// writable top layer; the remainder are read-only, in order of precedence.
+type cacheLayers []string
+
+// top returns the writable cache layer.
+func (cl cacheLayers) top() string {
+	return cl[0]
+}
+
+// findLower searches the read-only layers, in order, for the given path
+// (relative to a cache root), returning the first that exists.
+func (cl cacheLayers) findLower(rel string) (string, bool) {
+	for _, dir := range cl[1:] {
+		path := filepath.Join(dir, rel)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
 // UseDefaultSignalHandling sets up typical os.Interrupt signal handling for a
 // SourceMgr.
 func (sm *SourceMgr) UseDefaultSignalHandling() {
@@ -251,8 +429,8 @@ func (sm *SourceMgr) StopSignalHandling() {
 }
 
 // CouldNotCreateLockError describe failure modes in which creating a SourceMgr
-// did not succeed because there was an error while attempting to create the
-// on-disk lock file.
+// did not succeed because there was an error while attempting to acquire the
+// shared lock on the cache directory.
 type CouldNotCreateLockError struct {
 	Path string
 	Err  error
@@ -286,9 +464,9 @@ func (sm *SourceMgr) doRelease() {
 	sm.cancelAll()
 	sm.suprvsr.wait()
 
-	// Close the file handle for the lock file and remove it from disk
-	sm.lf.Close()
-	os.Remove(filepath.Join(sm.cachedir, "sm.lock"))
+	// Drop the shared cache lock. The lock file itself is left in place, as
+	// other processes may be relying on it.
+	sm.glock.close()
 
 	// Close the qch, if non-nil, so the signal handlers run out. This will
 	// also deregister the sig channel, if any has been set up.
@@ -297,36 +475,109 @@ func (sm *SourceMgr) doRelease() {
 	}
 }
 
+// SetConcurrencyLimits changes the limits on how many network-bound and
+// CPU-bound calls the SourceMgr will run at once. Calls already running are
+// unaffected.
+//
+// By default, a SourceMgr uses DefaultConcurrencyLimits().
+func (sm *SourceMgr) SetConcurrencyLimits(lim ConcurrencyLimits) {
+	sm.suprvsr.sched.setLimits(lim)
+}
+
+// ConcurrencyLimits returns the SourceMgr's current concurrency limits.
+func (sm *SourceMgr) ConcurrencyLimits() ConcurrencyLimits {
+	return sm.suprvsr.sched.limits()
+}
+
+// SetDeductionTTL sets the length of time for which the persisted results of
+// fetching go-get metadata are used without being refreshed. A TTL of zero or
+// less means metadata is always refreshed.
+//
+// Regardless of TTL, persisted results are used as a fallback when fresh
+// metadata cannot be retrieved - for example, if a vanity import host is
+// down.
+//
+// By default, a SourceMgr uses DefaultDeductionTTL.
+func (sm *SourceMgr) SetDeductionTTL(ttl time.Duration) {
+	sm.deduceCoord.store.setTTL(ttl)
+}
+
+// SetSchemePolicy sets the policy governing which URL schemes the SourceMgr
+// may use to access sources, and in what order they are preferred. The
+// policy applies to sources set up after it is set; sources already in use
+// are unaffected.
+//
+// By default, all schemes are allowed, in the order each type of source
+// prefers.
+func (sm *SourceMgr) SetSchemePolicy(p SchemePolicy) {
+	sm.srcCoord.schemes.set(p)
+}
+
+// SchemePolicy returns the SourceMgr's current SchemePolicy.
+func (sm *SourceMgr) SchemePolicy() SchemePolicy {
+	return sm.srcCoord.schemes.get()
+}
+
+// ListDeductions returns all persisted results of fetching go-get metadata,
+// sorted by root import path.
+func (sm *SourceMgr) ListDeductions() ([]DeductionRecord, error) {
+	return sm.deduceCoord.store.list()
+}
+
+// InvalidateDeductions removes the persisted go-get metadata results for the
+// provided root import paths, so that the metadata will be fetched anew the
+// next time it's needed. If no roots are provided, all persisted results are
+// removed.
+//
+// Results in read-only lower cache layers can't be removed. They are instead
+// marked stale in the writable layer, with a zero Fetched time, so that they
+// are only used as a fallback if the metadata can't be fetched.
+func (sm *SourceMgr) InvalidateDeductions(roots ...string) error {
+	return sm.deduceCoord.invalidate(roots...)
+}
+
 // GetManifestAndLock returns manifest and lock information for the provided
 // ProjectIdentifier, at the provided Version. The work of producing the
 // manifest and lock is delegated to the provided ProjectAnalyzer's
 // DeriveManifestAndLock() method.
 func (sm *SourceMgr) GetManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	return sm.GetManifestAndLockContext(context.TODO(), id, v, an)
+}
+
+// GetManifestAndLockContext is the same as GetManifestAndLock, but its work may
+// be canceled via the provided context.
+func (sm *SourceMgr) GetManifestAndLockContext(ctx context.Context, id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return nil, nil, smIsReleased{}
 	}
 
-	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return srcg.getManifestAndLock(context.TODO(), id.ProjectRoot, v, an)
+	return srcg.getManifestAndLock(ctx, id.ProjectRoot, v, an)
 }
 
 // ListPackages parses the tree of the Go packages at and below the ProjectRoot
 // of the given ProjectIdentifier, at the given version.
 func (sm *SourceMgr) ListPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	return sm.ListPackagesContext(context.TODO(), id, v)
+}
+
+// ListPackagesContext is the same as ListPackages, but its work may be canceled
+// via the provided context.
+func (sm *SourceMgr) ListPackagesContext(ctx context.Context, id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return pkgtree.PackageTree{}, smIsReleased{}
 	}
 
-	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
 		return pkgtree.PackageTree{}, err
 	}
 
-	return srcg.listPackages(context.TODO(), id.ProjectRoot, v)
+	return srcg.listPackages(ctx, id.ProjectRoot, v)
 }
 
 // ListVersions retrieves a list of the available versions for a given
@@ -342,48 +593,65 @@ func (sm *SourceMgr) ListPackages(id ProjectIdentifier, v Version) (pkgtree.Pack
 // is not accessible (network outage, access issues, or the resource actually
 // went away), an error will be returned.
 func (sm *SourceMgr) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	return sm.ListVersionsContext(context.TODO(), id)
+}
+
+// ListVersionsContext is the same as ListVersions, but its work may be canceled
+// via the provided context.
+func (sm *SourceMgr) ListVersionsContext(ctx context.Context, id ProjectIdentifier) ([]PairedVersion, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return nil, smIsReleased{}
 	}
 
-	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
 		// TODO(sdboyer) More-er proper-er errors
 		return nil, err
 	}
 
-	return srcg.listVersions(context.TODO())
+	return srcg.listVersions(ctx)
 }
 
 // RevisionPresentIn indicates whether the provided Revision is present in the given
 // repository.
 func (sm *SourceMgr) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
+	return sm.RevisionPresentInContext(context.TODO(), id, r)
+}
+
+// RevisionPresentInContext is the same as RevisionPresentIn, but its work may
+// be canceled via the provided context.
+func (sm *SourceMgr) RevisionPresentInContext(ctx context.Context, id ProjectIdentifier, r Revision) (bool, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return false, smIsReleased{}
 	}
 
-	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
 		// TODO(sdboyer) More-er proper-er errors
 		return false, err
 	}
 
-	return srcg.revisionPresentIn(context.TODO(), r)
+	return srcg.revisionPresentIn(ctx, r)
 }
 
 // SourceExists checks if a repository exists, either upstream or in the cache,
 // for the provided ProjectIdentifier.
 func (sm *SourceMgr) SourceExists(id ProjectIdentifier) (bool, error) {
+	return sm.SourceExistsContext(context.TODO(), id)
+}
+
+// SourceExistsContext is the same as SourceExists, but its work may be canceled
+// via the provided context.
+func (sm *SourceMgr) SourceExistsContext(ctx context.Context, id ProjectIdentifier) (bool, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return false, smIsReleased{}
 	}
 
-	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
 		return false, err
 	}
 
-	ctx := context.TODO()
 	return srcg.existsInCache(ctx) || srcg.existsUpstream(ctx), nil
 }
 
@@ -392,31 +660,43 @@ func (sm *SourceMgr) SourceExists(id ProjectIdentifier) (bool, error) {
 //
 // The primary use case for this is prefetching.
 func (sm *SourceMgr) SyncSourceFor(id ProjectIdentifier) error {
+	return sm.SyncSourceForContext(context.TODO(), id)
+}
+
+// SyncSourceForContext is the same as SyncSourceFor, but its work may be
+// canceled via the provided context.
+func (sm *SourceMgr) SyncSourceForContext(ctx context.Context, id ProjectIdentifier) error {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return smIsReleased{}
 	}
 
-	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	return srcg.syncLocal(context.TODO())
+	return srcg.syncLocal(ctx)
 }
 
 // ExportProject writes out the tree of the provided ProjectIdentifier's
 // ProjectRoot, at the provided version, to the provided directory.
 func (sm *SourceMgr) ExportProject(id ProjectIdentifier, v Version, to string) error {
+	return sm.ExportProjectContext(context.TODO(), id, v, to)
+}
+
+// ExportProjectContext is the same as ExportProject, but its work may be
+// canceled via the provided context.
+func (sm *SourceMgr) ExportProjectContext(ctx context.Context, id ProjectIdentifier, v Version, to string) error {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return smIsReleased{}
 	}
 
-	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	return srcg.exportVersionTo(context.TODO(), v, to)
+	return srcg.exportVersionTo(ctx, v, to)
 }
 
 // DeduceProjectRoot takes an import path and deduces the corresponding
@@ -427,11 +707,17 @@ func (sm *SourceMgr) ExportProject(id ProjectIdentifier, v Version, to string) e
 // paths. (A special exception is written for gopkg.in to minimize network
 // activity, as its behavior is well-structured)
 func (sm *SourceMgr) DeduceProjectRoot(ip string) (ProjectRoot, error) {
+	return sm.DeduceProjectRootContext(context.TODO(), ip)
+}
+
+// DeduceProjectRootContext is the same as DeduceProjectRoot, but its work may
+// be canceled via the provided context.
+func (sm *SourceMgr) DeduceProjectRootContext(ctx context.Context, ip string) (ProjectRoot, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return "", smIsReleased{}
 	}
 
-	pd, err := sm.deduceCoord.deduceRootPath(context.TODO(), ip)
+	pd, err := sm.deduceCoord.deduceRootPath(ctx, ip)
 	return ProjectRoot(pd.root), err
 }
 
@@ -448,6 +734,7 @@ type durCount struct {
 type supervisor struct {
 	ctx        context.Context
 	cancelFunc context.CancelFunc
+	sched      *scheduler // Gates calls according to concurrency limits
 	mu         sync.Mutex // Guards all maps
 	cond       sync.Cond  // Wraps mu so callers can wait until all calls end
 	running    map[callInfo]timeCount
@@ -459,6 +746,7 @@ func newSupervisor(ctx context.Context) *supervisor {
 	supv := &supervisor{
 		ctx:        ctx,
 		cancelFunc: cf,
+		sched:      newScheduler(DefaultConcurrencyLimits()),
 		running:    make(map[callInfo]timeCount),
 		ran:        make(map[callType]durCount),
 	}
@@ -470,7 +758,16 @@ func newSupervisor(ctx context.Context) *supervisor {
 // do executes the incoming closure using a conjoined context, and keeps
 // counters to ensure the sourceMgr can't finish Release()ing until after all
 // calls have returned.
+//
+// The call does not begin until the scheduler permits it. Network-bound calls
+// made via do() are subject only to the global network limit; use doHost()
+// to also apply the per-host limit.
 func (sup *supervisor) do(inctx context.Context, name string, typ callType, f func(context.Context) error) error {
+	return sup.doHost(inctx, "", name, typ, f)
+}
+
+// doHost is the same as do, but for calls that talk to the provided host.
+func (sup *supervisor) doHost(inctx context.Context, host, name string, typ callType, f func(context.Context) error) error {
 	ci := callInfo{
 		name: name,
 		typ:  typ,
@@ -482,7 +779,11 @@ func (sup *supervisor) do(inctx context.Context, name string, typ callType, f fu
 	}
 
 	cctx, cancelFunc := constext.Cons(inctx, octx)
-	err = f(cctx)
+	release, err := sup.sched.acquire(cctx, typ, host)
+	if err == nil {
+		err = f(cctx)
+		release()
+	}
 	sup.done(ci)
 	cancelFunc()
 	return err
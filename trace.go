@@ -113,9 +113,15 @@ func (s *solver) traceSelectRoot(ptree pkgtree.PackageTree, cdeps []completeDep)
 
 	// This duplicates work a bit, but we're in trace mode and it's only once,
 	// so who cares
-	rm, _ := ptree.ToReachMap(true, true, false, s.rd.ig)
+	rm, _ := ptree.ToReachMapFor(true, true, false, s.rd.ws[0].ig, s.rd.targets)
 
 	s.tl.Printf("Root project is %q", s.rd.rpt.ImportRoot)
+	for _, r := range s.rd.workspaceRoots() {
+		s.tl.Printf("Workspace also includes %q", r)
+	}
+	for _, t := range s.rd.targets {
+		s.tl.Printf("Building for %s", t)
+	}
 
 	var expkgs int
 	for _, cdep := range cdeps {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -69,6 +70,17 @@ func (bs *baseVCSSource) initLocal(ctx context.Context) error {
 	return nil
 }
 
+// initLocalFrom creates the local copy of the source by copying an existing
+// local copy, such as one from a read-only cache layer. Sources whose VCS can
+// clone from, or share with, a local copy implement their own.
+func (bs *baseVCSSource) initLocalFrom(ctx context.Context, from string) error {
+	return fs.CopyDir(from, bs.repo.LocalPath())
+}
+
+func (bs *baseVCSSource) localPath() string {
+	return bs.repo.LocalPath()
+}
+
 // updateLocal ensures the local data (versions and code) we have about the
 // source is fully up to date with that of the canonical upstream source.
 func (bs *baseVCSSource) updateLocal(ctx context.Context) error {
@@ -156,6 +168,27 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	return nil
 }
 
+// initLocalFrom creates the local repository as a shared clone of an existing
+// local repository, such as one from a read-only cache layer. Objects are
+// borrowed from the existing repository via git's alternates mechanism rather
+// than copied, so the existing repository must remain in place.
+func (s *gitSource) initLocalFrom(ctx context.Context, from string) error {
+	r := s.repo
+
+	out, err := runFromCwd(ctx, "git", "clone", "--shared", "--no-checkout", from, r.LocalPath())
+	if err != nil {
+		return fmt.Errorf("%s: %s", out, err)
+	}
+
+	// Point back at the real upstream so that later fetches go there.
+	out, err = runFromRepoDir(ctx, r, "git", "remote", "set-url", "origin", r.Remote())
+	if err != nil {
+		return fmt.Errorf("%s: %s", out, err)
+	}
+
+	return nil
+}
+
 func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, err error) {
 	r := s.repo
 
@@ -344,6 +377,29 @@ type bzrSource struct {
 	baseVCSSource
 }
 
+// initLocalFrom creates the local branch as one stacked on an existing local
+// branch, such as one from a read-only cache layer. Revisions are read from the
+// existing branch rather than copied, so it must remain in place.
+func (s *bzrSource) initLocalFrom(ctx context.Context, from string) error {
+	r := s.repo
+
+	if err := os.MkdirAll(filepath.Dir(r.LocalPath()), 0777); err != nil {
+		return err
+	}
+	out, err := runFromCwd(ctx, "bzr", "branch", "--stacked", from, r.LocalPath())
+	if err != nil {
+		return fmt.Errorf("%s: %s", out, err)
+	}
+
+	// Point back at the real upstream so that later pulls go there.
+	out, err = runFromRepoDir(ctx, r, "bzr", "config", "--scope=branch", "parent_location="+r.Remote())
+	if err != nil {
+		return fmt.Errorf("%s: %s", out, err)
+	}
+
+	return nil
+}
+
 func (s *bzrSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
 	r := s.repo
 
@@ -386,6 +442,26 @@ type hgSource struct {
 	baseVCSSource
 }
 
+// initLocalFrom creates the local repository by cloning an existing local
+// repository, such as one from a read-only cache layer. Mercurial hardlinks the
+// store's files, rather than copying them, wherever it can, and breaks the
+// links before writing to them, so the existing repository is never modified.
+func (s *hgSource) initLocalFrom(ctx context.Context, from string) error {
+	r := s.repo
+
+	if err := os.MkdirAll(filepath.Dir(r.LocalPath()), 0777); err != nil {
+		return err
+	}
+	out, err := runFromCwd(ctx, "hg", "clone", "--noupdate", from, r.LocalPath())
+	if err != nil {
+		return fmt.Errorf("%s: %s", out, err)
+	}
+
+	// Point back at the real upstream so that later pulls go there.
+	hgrc := fmt.Sprintf("[paths]\ndefault = %s\n", r.Remote())
+	return ioutil.WriteFile(filepath.Join(r.LocalPath(), ".hg", "hgrc"), []byte(hgrc), 0666)
+}
+
 func (s *hgSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
 	var vlist []PairedVersion
 
@@ -4,10 +4,15 @@ import (
 	"context"
 	"io/ioutil"
 	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
+
+	"github.com/Masterminds/vcs"
 )
 
 // Parent test that executes all the slow vcs interaction tests in parallel.
@@ -516,3 +521,212 @@ func requiresBins(t *testing.T, bins ...string) {
 		}
 	}
 }
+
+func TestGitSourceInitLocalFrom(t *testing.T) {
+	requiresBins(t, "git")
+
+	ctx := context.Background()
+	tempDir, err := ioutil.TempDir("", "gitseedtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := removeAll(tempDir); err != nil {
+			t.Errorf("removeAll failed: %s", err)
+		}
+	}()
+
+	// Set up a repository with a single commit to act as the read-only copy.
+	lower := filepath.Join(tempDir, "lower")
+	git := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = mergeEnvLists([]string{
+			"GIT_AUTHOR_NAME=gps", "GIT_AUTHOR_EMAIL=gps@example.com",
+			"GIT_COMMITTER_NAME=gps", "GIT_COMMITTER_EMAIL=gps@example.com",
+		}, os.Environ())
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if err = os.MkdirAll(lower, 0777); err != nil {
+		t.Fatal(err)
+	}
+	git(lower, "init")
+	if err = ioutil.WriteFile(filepath.Join(lower, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git(lower, "add", "a.go")
+	git(lower, "commit", "-m", "initial")
+	rev := git(lower, "rev-parse", "HEAD")
+
+	remote := "https://example.com/gps/seeded"
+	upper := filepath.Join(tempDir, "upper")
+	r, err := vcs.NewGitRepo(remote, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{
+		baseVCSSource: baseVCSSource{
+			repo: &gitRepo{r},
+		},
+	}
+
+	if err = src.initLocalFrom(ctx, lower); err != nil {
+		t.Fatalf("Unexpected error seeding from local repository: %s", err)
+	}
+
+	if !src.existsLocally(ctx) {
+		t.Fatal("Source should exist locally after seeding")
+	}
+
+	if present, err := src.revisionPresentIn(Revision(rev)); err != nil || !present {
+		t.Errorf("Revision %s from seed repository should be present, got %v (err: %v)", rev, present, err)
+	}
+
+	if got := git(upper, "config", "remote.origin.url"); got != remote {
+		t.Errorf("Expected seeded repository's origin to be %q, got %q", remote, got)
+	}
+}
+
+func TestHgSourceInitLocalFrom(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping hg seeding test in short mode")
+	}
+	requiresBins(t, "hg")
+
+	ctx := context.Background()
+	tempDir, err := ioutil.TempDir("", "hgseedtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := removeAll(tempDir); err != nil {
+			t.Errorf("removeAll failed: %s", err)
+		}
+	}()
+
+	// Set up a repository with a single commit to act as the read-only copy.
+	lower := filepath.Join(tempDir, "lower")
+	hg := func(dir string, args ...string) string {
+		cmd := exec.Command("hg", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("hg %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if err = os.MkdirAll(lower, 0777); err != nil {
+		t.Fatal(err)
+	}
+	hg(lower, "init")
+	if err = ioutil.WriteFile(filepath.Join(lower, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	hg(lower, "add", "a.go")
+	hg(lower, "commit", "-u", "gps", "-m", "initial")
+	rev := hg(lower, "log", "-r", "tip", "--template", "{node}")
+
+	remote := "https://example.com/gps/seeded"
+	upper := filepath.Join(tempDir, "upper", "seeded")
+	r, err := vcs.NewHgRepo(remote, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &hgSource{
+		baseVCSSource: baseVCSSource{
+			repo: &hgRepo{r},
+		},
+	}
+
+	if err = src.initLocalFrom(ctx, lower); err != nil {
+		t.Fatalf("Unexpected error seeding from local repository: %s", err)
+	}
+
+	if !src.existsLocally(ctx) {
+		t.Fatal("Source should exist locally after seeding")
+	}
+
+	if present, err := src.revisionPresentIn(Revision(rev)); err != nil || !present {
+		t.Errorf("Revision %s from seed repository should be present, got %v (err: %v)", rev, present, err)
+	}
+
+	if got := hg(upper, "paths", "default"); got != remote {
+		t.Errorf("Expected seeded repository's default path to be %q, got %q", remote, got)
+	}
+}
+
+func TestBzrSourceInitLocalFrom(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping bzr seeding test in short mode")
+	}
+	requiresBins(t, "bzr")
+
+	ctx := context.Background()
+	tempDir, err := ioutil.TempDir("", "bzrseedtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := removeAll(tempDir); err != nil {
+			t.Errorf("removeAll failed: %s", err)
+		}
+	}()
+
+	// Set up a branch with a single commit to act as the read-only copy.
+	lower := filepath.Join(tempDir, "lower")
+	bzr := func(dir string, args ...string) string {
+		cmd := exec.Command("bzr", args...)
+		cmd.Dir = dir
+		cmd.Env = mergeEnvLists([]string{"BZR_EMAIL=gps <gps@example.com>"}, os.Environ())
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("bzr %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if err = os.MkdirAll(lower, 0777); err != nil {
+		t.Fatal(err)
+	}
+	bzr(lower, "init")
+	if err = ioutil.WriteFile(filepath.Join(lower, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	bzr(lower, "add", "a.go")
+	bzr(lower, "commit", "-m", "initial")
+	rev := bzr(lower, "version-info", "--custom", "--template={revision_id}")
+
+	remote := "https://example.com/gps/seeded"
+	upper := filepath.Join(tempDir, "upper", "seeded")
+	r, err := vcs.NewBzrRepo(remote, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &bzrSource{
+		baseVCSSource: baseVCSSource{
+			repo: &bzrRepo{r},
+		},
+	}
+
+	if err = src.initLocalFrom(ctx, lower); err != nil {
+		t.Fatalf("Unexpected error seeding from local repository: %s", err)
+	}
+
+	if !src.existsLocally(ctx) {
+		t.Fatal("Source should exist locally after seeding")
+	}
+
+	if present, err := src.revisionPresentIn(Revision(rev)); err != nil || !present {
+		t.Errorf("Revision %s from seed branch should be present, got %v (err: %v)", rev, present, err)
+	}
+
+	if got := bzr(upper, "config", "parent_location"); got != remote {
+		t.Errorf("Expected seeded branch's parent to be %q, got %q", remote, got)
+	}
+}
@@ -0,0 +1,83 @@
+package gps
+
+import "sort"
+
+// A VersionPreference determines the order in which the solver tries the
+// candidate versions of a project.
+//
+// The preference is consulted only when the solver falls back on the full
+// list of a project's versions; a version from the root lock, or preferred by
+// a dependency's lock, is still tried first.
+type VersionPreference interface {
+	// PreferVersions reorders the provided versions of the project in place,
+	// most preferred first.
+	//
+	// The versions arrive in the solver's default order, as produced by
+	// SortForUpgrade (or SortForDowngrade, if downgrading). The slice is the
+	// solver's own copy, so it is safe to reorder, but it must not be grown or
+	// shrunk.
+	PreferVersions(id ProjectIdentifier, pc PreferenceContext, vl []Version)
+}
+
+// PreferenceContext describes the state of the solve in which a
+// VersionPreference is asked to order a project's versions.
+type PreferenceContext struct {
+	// Constraint is the effective constraint on the project: the intersection
+	// of the constraints placed on it by the root and by every selected
+	// project that depends on it. Versions that don't satisfy it will be
+	// rejected, whatever their order.
+	Constraint Constraint
+
+	// LockVersion is the version of the project in the root lock, or nil if
+	// there is none, or if it is to be changed.
+	LockVersion Version
+
+	// PreferredVersion is the version of the project in the lock of a
+	// dependency, or nil if there is none.
+	PreferredVersion Version
+}
+
+// VersionScorer is a VersionPreference that orders versions by a score,
+// highest first. Versions with equal scores are kept in the default order, so
+// a scorer need only distinguish the versions it cares about.
+//
+// For example, to try pre-release versions only after all others:
+//
+//	gps.VersionScorer(func(id gps.ProjectIdentifier, pc gps.PreferenceContext, v gps.Version) int {
+//		if v.Type() == gps.IsSemver && strings.Contains(v.String(), "-") {
+//			return -1
+//		}
+//		return 0
+//	})
+type VersionScorer func(id ProjectIdentifier, pc PreferenceContext, v Version) int
+
+// PreferVersions implements VersionPreference.
+func (f VersionScorer) PreferVersions(id ProjectIdentifier, pc PreferenceContext, vl []Version) {
+	sv := scoredVersions{
+		vl:     vl,
+		scores: make([]int, len(vl)),
+	}
+	for k, v := range vl {
+		sv.scores[k] = f(id, pc, v)
+	}
+
+	sort.Stable(sv)
+}
+
+type scoredVersions struct {
+	vl     []Version
+	scores []int
+}
+
+func (s scoredVersions) Len() int {
+	return len(s.vl)
+}
+
+func (s scoredVersions) Swap(i, j int) {
+	s.vl[i], s.vl[j] = s.vl[j], s.vl[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
+
+func (s scoredVersions) Less(i, j int) bool {
+	return s.scores[i] > s.scores[j]
+}
@@ -15,16 +15,21 @@ type versionQueue struct {
 	pi           []Version
 	lockv, prefv Version
 	fails        []failedVersion
+	reasons      []*nogood // the nogood explaining each of fails, if known
 	b            sourceBridge
+	pref         VersionPreference
+	constraint   Constraint
 	failed       bool
 	allLoaded    bool
 	adverr       error
 }
 
-func newVersionQueue(id ProjectIdentifier, lockv, prefv Version, b sourceBridge) (*versionQueue, error) {
+func newVersionQueue(id ProjectIdentifier, lockv, prefv Version, b sourceBridge, pref VersionPreference, c Constraint) (*versionQueue, error) {
 	vq := &versionQueue{
-		id: id,
-		b:  b,
+		id:         id,
+		b:          b,
+		pref:       pref,
+		constraint: c,
 	}
 
 	// Lock goes in first, if present
@@ -41,7 +46,7 @@ func newVersionQueue(id ProjectIdentifier, lockv, prefv Version, b sourceBridge)
 
 	if len(vq.pi) == 0 {
 		var err error
-		vq.pi, err = vq.b.listVersions(vq.id)
+		vq.pi, err = vq.listVersions()
 		if err != nil {
 			// TODO(sdboyer) pushing this error this early entails that we
 			// unconditionally deep scan (e.g. vendor), as well as hitting the
@@ -54,6 +59,29 @@ func newVersionQueue(id ProjectIdentifier, lockv, prefv Version, b sourceBridge)
 	return vq, nil
 }
 
+// listVersions returns a copy of the project's full version list, ordered
+// according to the queue's VersionPreference, if any.
+func (vq *versionQueue) listVersions() ([]Version, error) {
+	vltmp, err := vq.b.listVersions(vq.id)
+	if err != nil {
+		return nil, err
+	}
+
+	// defensive copy - the list may be reordered here, or have its contents
+	// modified when removing prefv/lockv.
+	vl := make([]Version, len(vltmp))
+	copy(vl, vltmp)
+
+	if vq.pref != nil {
+		vq.pref.PreferVersions(vq.id, PreferenceContext{
+			Constraint:       vq.constraint,
+			LockVersion:      vq.lockv,
+			PreferredVersion: vq.prefv,
+		}, vl)
+	}
+	return vl, nil
+}
+
 func (vq *versionQueue) current() Version {
 	if len(vq.pi) > 0 {
 		return vq.pi[0]
@@ -65,6 +93,12 @@ func (vq *versionQueue) current() Version {
 // advance moves the versionQueue forward to the next available version,
 // recording the failure that eliminated the current version.
 func (vq *versionQueue) advance(fail error) error {
+	return vq.advanceWith(fail, nil)
+}
+
+// advanceWith is the same as advance, but also records the nogood, if any,
+// that explains the failure.
+func (vq *versionQueue) advanceWith(fail error, reason *nogood) error {
 	// Nothing in the queue means...nothing in the queue, nicely enough
 	if vq.adverr != nil || len(vq.pi) == 0 { // should be a redundant check, but just in case
 		return vq.adverr
@@ -75,6 +109,7 @@ func (vq *versionQueue) advance(fail error) error {
 		v: vq.pi[0],
 		f: fail,
 	})
+	vq.reasons = append(vq.reasons, reason)
 	vq.pi = vq.pi[1:]
 
 	// *now*, if the queue is empty, ensure all versions have been loaded
@@ -86,15 +121,10 @@ func (vq *versionQueue) advance(fail error) error {
 		}
 		vq.allLoaded = true
 
-		var vltmp []Version
-		vltmp, vq.adverr = vq.b.listVersions(vq.id)
+		vq.pi, vq.adverr = vq.listVersions()
 		if vq.adverr != nil {
 			return vq.adverr
 		}
-		// defensive copy - calling listVersions here means slice contents may
-		// be modified when removing prefv/lockv.
-		vq.pi = make([]Version, len(vltmp))
-		copy(vq.pi, vltmp)
 
 		// search for and remove lockv and prefv, in a pointer GC-safe manner
 		//
@@ -2,6 +2,7 @@ package gps
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -53,12 +54,12 @@ func TestVersionQueueSetup(t *testing.T) {
 	fb := &fakeBridge{vl: fakevl}
 	ffb := &fakeFailBridge{}
 
-	_, err := newVersionQueue(id, nil, nil, ffb)
+	_, err := newVersionQueue(id, nil, nil, ffb, nil, nil)
 	if err == nil {
 		t.Error("Expected err when providing no prefv or lockv, and injected bridge returns err from ListVersions()")
 	}
 
-	vq, err := newVersionQueue(id, nil, nil, fb)
+	vq, err := newVersionQueue(id, nil, nil, fb, nil, nil)
 	if err != nil {
 		t.Errorf("Unexpected err on vq create: %s", err)
 	} else {
@@ -79,7 +80,7 @@ func TestVersionQueueSetup(t *testing.T) {
 
 	lockv := fakevl[0]
 	prefv := fakevl[1]
-	vq, err = newVersionQueue(id, lockv, nil, fb)
+	vq, err = newVersionQueue(id, lockv, nil, fb, nil, nil)
 	if err != nil {
 		t.Errorf("Unexpected err on vq create: %s", err)
 	} else {
@@ -97,7 +98,7 @@ func TestVersionQueueSetup(t *testing.T) {
 		}
 	}
 
-	vq, err = newVersionQueue(id, nil, prefv, fb)
+	vq, err = newVersionQueue(id, nil, prefv, fb, nil, nil)
 	if err != nil {
 		t.Errorf("Unexpected err on vq create: %s", err)
 	} else {
@@ -115,7 +116,7 @@ func TestVersionQueueSetup(t *testing.T) {
 		}
 	}
 
-	vq, err = newVersionQueue(id, lockv, prefv, fb)
+	vq, err = newVersionQueue(id, lockv, prefv, fb, nil, nil)
 	if err != nil {
 		t.Errorf("Unexpected err on vq create: %s", err)
 	} else {
@@ -142,7 +143,7 @@ func TestVersionQueueAdvance(t *testing.T) {
 	id := ProjectIdentifier{ProjectRoot: ProjectRoot("foo")}.normalize()
 
 	// First with no prefv or lockv
-	vq, err := newVersionQueue(id, nil, nil, fb)
+	vq, err := newVersionQueue(id, nil, nil, fb, nil, nil)
 	if err != nil {
 		t.Fatalf("Unexpected err on vq create: %s", err)
 	}
@@ -176,7 +177,7 @@ func TestVersionQueueAdvance(t *testing.T) {
 	// now, do one with both a prefv and lockv
 	lockv := fakevl[2]
 	prefv := fakevl[0]
-	vq, err = newVersionQueue(id, lockv, prefv, fb)
+	vq, err = newVersionQueue(id, lockv, prefv, fb, nil, nil)
 	if vq.String() != "[v1.1.0, v2.0.0]" {
 		t.Error("stringifying vq did not have expected outcome, got", vq.String())
 	}
@@ -227,7 +228,7 @@ func TestVersionQueueAdvance(t *testing.T) {
 
 	// Make sure we handle things correctly when listVersions adds nothing new
 	fb = &fakeBridge{vl: []Version{lockv, prefv}}
-	vq, err = newVersionQueue(id, lockv, prefv, fb)
+	vq, err = newVersionQueue(id, lockv, prefv, fb, nil, nil)
 	vq.advance(nil)
 	vq.advance(nil)
 	if vq.current() != nil || !vq.isExhausted() {
@@ -240,7 +241,7 @@ func TestVersionQueueAdvance(t *testing.T) {
 
 	// Also handle it well when advancing calls ListVersions() and it gets an
 	// error
-	vq, err = newVersionQueue(id, lockv, nil, &fakeFailBridge{})
+	vq, err = newVersionQueue(id, lockv, nil, &fakeFailBridge{}, nil, nil)
 	if err != nil {
 		t.Errorf("should not err on creation when preseeded with lockv, but got err %s", err)
 	}
@@ -254,3 +255,65 @@ func TestVersionQueueAdvance(t *testing.T) {
 	}
 
 }
+
+func TestVersionQueuePreference(t *testing.T) {
+	id := ProjectIdentifier{ProjectRoot: ProjectRoot("foo")}.normalize()
+	fb := &fakeBridge{vl: fakevl}
+
+	// Prefer the branch, then anything in the v1.1 line.
+	var got PreferenceContext
+	pref := VersionScorer(func(pid ProjectIdentifier, pc PreferenceContext, v Version) int {
+		if !pid.eq(id) {
+			t.Errorf("scorer called with unexpected id %s", pid)
+		}
+		got = pc
+		switch {
+		case v.Type() == IsBranch:
+			return 2
+		case strings.HasPrefix(v.String(), "v1.1"):
+			return 1
+		}
+		return 0
+	})
+
+	vq, err := newVersionQueue(id, nil, nil, fb, pref, Any())
+	if err != nil {
+		t.Fatalf("Unexpected err on vq create: %s", err)
+	}
+
+	want := []Version{fakevl[4], fakevl[1], fakevl[2], fakevl[0], fakevl[3]}
+	if len(vq.pi) != len(want) {
+		t.Fatalf("expected %v versions, got %v:\n\t%s", len(want), len(vq.pi), vq.String())
+	}
+	for k, v := range want {
+		if vq.pi[k] != v {
+			t.Errorf("version %v: expected %s, got %s", k, v, vq.pi[k])
+		}
+	}
+
+	// The bridge's list must be left alone.
+	if fb.vl[0] != NewVersion("v2.0.0").Is("200rev") {
+		t.Errorf("preference should not reorder the bridge's version list; got %s first", fb.vl[0])
+	}
+
+	// The preference also applies when loading versions after exhausting the
+	// lock version.
+	lockv := fakevl[0]
+	c := mkSVC("^1.0.0")
+	vq, err = newVersionQueue(id, lockv, nil, fb, pref, c)
+	if err != nil {
+		t.Fatalf("Unexpected err on vq create: %s", err)
+	}
+	if err = vq.advance(nil); err != nil {
+		t.Fatalf("Unexpected err on vq advance: %s", err)
+	}
+	if got.Constraint.String() != c.String() || got.LockVersion != lockv || got.PreferredVersion != nil {
+		t.Errorf("preference given unexpected context: %+v", got)
+	}
+	want = []Version{fakevl[4], fakevl[1], fakevl[2], fakevl[3]}
+	for k, v := range want {
+		if k >= len(vq.pi) || vq.pi[k] != v {
+			t.Fatalf("after advance, expected %v, got %v", want, vq.pi)
+		}
+	}
+}
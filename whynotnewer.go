@@ -0,0 +1,263 @@
+package gps
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// HoldKind categorizes the reasons a newer version of a project was not
+// selected.
+type HoldKind uint8
+
+// HoldKinds for each of the reasons a project can be held back.
+const (
+	// HeldByConstraint indicates a constraint from the root project or a
+	// dependency does not allow the newer version.
+	HeldByConstraint HoldKind = iota
+	// HeldByOverride indicates an override in the root manifest does not allow
+	// the newer version.
+	HeldByOverride
+	// HeldByRootLock indicates the version in the root lock was preserved.
+	HeldByRootLock
+	// HeldByDependencyLock indicates the version was preferred because a
+	// dependency's lock specified it.
+	HeldByDependencyLock
+	// HeldByDenyRule indicates the newer version is denied by a DenyRule.
+	HeldByDenyRule
+	// HeldByConflict indicates the newer version was tried, but failed in
+	// combination with the other selections.
+	HeldByConflict
+	// HeldByMVS indicates minimal version selection was used, and no
+	// constraint requires the newer version.
+	HeldByMVS
+	// HeldByDowngrade indicates the solver was asked to prefer older versions.
+	HeldByDowngrade
+	// HeldByPreference indicates a VersionPreference ordered the selected
+	// version before the newer one.
+	HeldByPreference
+	// HeldByUnknown indicates no reason could be determined.
+	HeldByUnknown
+)
+
+func (k HoldKind) String() string {
+	switch k {
+	case HeldByConstraint:
+		return "constraint"
+	case HeldByOverride:
+		return "override"
+	case HeldByRootLock:
+		return "root lock"
+	case HeldByDependencyLock:
+		return "dependency lock"
+	case HeldByDenyRule:
+		return "deny rule"
+	case HeldByConflict:
+		return "conflict"
+	case HeldByMVS:
+		return "minimal version selection"
+	case HeldByDowngrade:
+		return "downgrade"
+	case HeldByPreference:
+		return "version preference"
+	case HeldByUnknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("HoldKind(%d)", uint8(k))
+}
+
+// A Hold is one reason a project was held back from its newest version.
+type Hold struct {
+	Kind HoldKind
+	// Constraint is the constraint that does not allow the newer version, for
+	// HeldByConstraint and HeldByOverride.
+	Constraint *DependencyConstraint
+	// Rule is the rule that denies the newer version, for HeldByDenyRule.
+	Rule *DenyRule
+	// Err is the failure encountered when trying the newer version, for
+	// HeldByConflict.
+	Err error
+}
+
+func (h Hold) String() string {
+	switch {
+	case h.Constraint != nil:
+		return fmt.Sprintf("%s: %s", h.Kind, h.Constraint)
+	case h.Rule != nil:
+		return fmt.Sprintf("%s: %s", h.Kind, h.Rule)
+	case h.Err != nil:
+		return fmt.Sprintf("%s: %s", h.Kind, h.Err)
+	}
+	return h.Kind.String()
+}
+
+// A NewerVersionReport explains why a project in a Solution is not at its
+// newest available version.
+type NewerVersionReport struct {
+	ProjectRoot ProjectRoot
+	// Selected is the version in the solution.
+	Selected Version
+	// Newest is the first of the project's versions, as sorted by
+	// SortForUpgrade. It is nil if the project has no versions.
+	Newest Version
+	// Holds are the reasons Newest was not selected. There are none if
+	// Selected is Newest.
+	Holds []Hold
+}
+
+// UpToDate indicates whether the newest version was selected.
+func (r NewerVersionReport) UpToDate() bool {
+	return r.Newest == nil || sameVersion(r.Selected, r.Newest)
+}
+
+func (r NewerVersionReport) String() string {
+	var buf bytes.Buffer
+	if r.UpToDate() {
+		fmt.Fprintf(&buf, "%s: %s is the newest version\n", r.ProjectRoot, r.Selected)
+		return buf.String()
+	}
+
+	fmt.Fprintf(&buf, "%s: %s selected, but %s is newer\n", r.ProjectRoot, r.Selected, r.Newest)
+	for _, h := range r.Holds {
+		fmt.Fprintf(&buf, "\t%s\n", h)
+	}
+	return buf.String()
+}
+
+// heldData retains what the solver knew about a selected project, for
+// explaining later why it was not at a newer version.
+type heldData struct {
+	id          ProjectIdentifier
+	v           Version
+	constraints []DependencyConstraint
+	deny        []DenyRule
+	// The failures recorded while trying versions ahead of the selected one.
+	fails []failedVersion
+	// Whether the selected version came from the root lock, or from a
+	// dependency's lock.
+	locked, preferred bool
+}
+
+// heldSolveMode records how the solver chose between acceptable versions.
+type heldSolveMode struct {
+	mvs, downgrade, pref bool
+}
+
+// buildHeldData records a heldData for each selected project.
+func (s *solver) buildHeldData(g *depGraph) map[ProjectRoot]heldData {
+	vqs := make(map[ProjectRoot]*versionQueue, len(s.vqs))
+	for _, q := range s.vqs {
+		vqs[q.id.ProjectRoot] = q
+	}
+
+	held := make(map[ProjectRoot]heldData, len(g.versions))
+	for _, sel := range s.sel.projects[1:] {
+		if !sel.first {
+			continue
+		}
+
+		pr := sel.a.a.id.ProjectRoot
+		hd := heldData{
+			id:          sel.a.a.id,
+			v:           sel.a.a.v,
+			constraints: g.constraints[pr],
+			deny:        s.rd.deny[pr],
+		}
+		if q, has := vqs[pr]; has {
+			hd.fails = q.fails
+			hd.locked = q.lockv != nil && q.current() == q.lockv
+			hd.preferred = q.prefv != nil && q.current() == q.prefv
+		}
+		held[pr] = hd
+	}
+
+	return held
+}
+
+// whyNotNewer explains why the project is not at its newest version, as
+// listed by the SourceManager.
+func (hd heldData) whyNotNewer(sm SourceManager, mode heldSolveMode) (NewerVersionReport, error) {
+	r := NewerVersionReport{
+		ProjectRoot: hd.id.ProjectRoot,
+		Selected:    hd.v,
+	}
+
+	pvl, err := sm.ListVersions(hd.id)
+	if err != nil {
+		return r, err
+	}
+	if len(pvl) == 0 {
+		return r, nil
+	}
+
+	vl := hidePair(pvl)
+	SortForUpgrade(vl)
+	r.Newest = vl[0]
+	if r.UpToDate() {
+		return r, nil
+	}
+
+	for k, dc := range hd.constraints {
+		if !dc.Constraint.Matches(r.Newest) {
+			kind := HeldByConstraint
+			if dc.Overridden {
+				kind = HeldByOverride
+			}
+			r.Holds = append(r.Holds, Hold{Kind: kind, Constraint: &hd.constraints[k]})
+		}
+	}
+	for k, rule := range hd.deny {
+		if rule.Constraint.Matches(r.Newest) {
+			r.Holds = append(r.Holds, Hold{Kind: HeldByDenyRule, Rule: &hd.deny[k]})
+		}
+	}
+
+	if len(r.Holds) == 0 {
+		// Nothing rules the newer version out on its own, so it must have
+		// lost out to something else.
+		for _, fv := range hd.fails {
+			if sameVersion(fv.v, r.Newest) {
+				r.Holds = append(r.Holds, Hold{Kind: HeldByConflict, Err: fv.f})
+			}
+		}
+	}
+
+	switch {
+	case hd.locked:
+		r.Holds = append(r.Holds, Hold{Kind: HeldByRootLock})
+	case hd.preferred:
+		r.Holds = append(r.Holds, Hold{Kind: HeldByDependencyLock})
+	}
+
+	if len(r.Holds) == 0 {
+		switch {
+		case mode.mvs:
+			r.Holds = append(r.Holds, Hold{Kind: HeldByMVS})
+		case mode.downgrade:
+			r.Holds = append(r.Holds, Hold{Kind: HeldByDowngrade})
+		case mode.pref:
+			r.Holds = append(r.Holds, Hold{Kind: HeldByPreference})
+		default:
+			r.Holds = append(r.Holds, Hold{Kind: HeldByUnknown})
+		}
+	}
+
+	return r, nil
+}
+
+// sameVersion compares versions, including their underlying revisions if
+// both are paired.
+func sameVersion(v1, v2 Version) bool {
+	if v1 == nil || v2 == nil {
+		return v1 == v2
+	}
+
+	pv1, ok1 := v1.(PairedVersion)
+	pv2, ok2 := v2.(PairedVersion)
+	switch {
+	case ok1 && !ok2:
+		v1 = pv1.Unpair()
+	case ok2 && !ok1:
+		v2 = pv2.Unpair()
+	}
+	return v1.typedString() == v2.typedString()
+}
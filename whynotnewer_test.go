@@ -0,0 +1,111 @@
+package gps
+
+import (
+	"testing"
+)
+
+func TestWhyNotNewer(t *testing.T) {
+	fix := basicFixture{
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a *", "b ^1.0.0", "c *", "e *", "g *"),
+			mkDepspec("a 1.0.0", "d ^1.0.0"),
+			mkDepspec("b 1.0.0"),
+			mkDepspec("b 2.0.0"),
+			mkDepspec("c 1.0.0"),
+			mkDepspec("c 1.1.0"),
+			mkDepspec("d 1.0.0"),
+			mkDepspec("d 2.0.0"),
+			mkDepspec("e 1.0.0"),
+			mkDepspec("e 2.0.0", "d ^2.0.0"),
+			mkDepspec("g 1.0.0"),
+			mkDepspec("g 2.0.0"),
+		},
+	}
+
+	sm := newdepspecSM(fix.ds, nil)
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		Lock:            mklock("c 1.0.0"),
+		Deny: []DenyRule{
+			{ProjectRoot: "g", Constraint: NewVersion("2.0.0"), Reason: "ADV-1"},
+		},
+		Retain: true,
+	}
+
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+	soln, err := s.Solve()
+	if err != nil {
+		t.Fatalf("unexpected error while solving: %s", err)
+	}
+
+	table := []struct {
+		pr   ProjectRoot
+		kind HoldKind
+		from ProjectRoot
+	}{
+		{pr: "a"},
+		{pr: "b", kind: HeldByConstraint, from: "root"},
+		{pr: "c", kind: HeldByRootLock},
+		{pr: "d", kind: HeldByConstraint, from: "a"},
+		{pr: "e", kind: HeldByConflict},
+		{pr: "g", kind: HeldByDenyRule},
+	}
+
+	for _, fix := range table {
+		r, err := soln.(RetainedSolution).WhyNotNewer(fix.pr, sm)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", fix.pr, err)
+			continue
+		}
+
+		if fix.pr == "a" {
+			if !r.UpToDate() || len(r.Holds) != 0 {
+				t.Errorf("a: expected to be up to date, got:\n%s", r)
+			}
+			continue
+		}
+
+		if r.UpToDate() || r.Newest.String() != "2.0.0" && r.Newest.String() != "1.1.0" {
+			t.Errorf("%s: expected a newer version to be available, got:\n%s", fix.pr, r)
+			continue
+		}
+		if len(r.Holds) != 1 || r.Holds[0].Kind != fix.kind {
+			t.Errorf("%s: expected a single hold of kind %s, got:\n%s", fix.pr, fix.kind, r)
+			continue
+		}
+		if fix.from != "" && r.Holds[0].Constraint.Depender != fix.from {
+			t.Errorf("%s: expected the constraint from %s, got %s", fix.pr, fix.from, r.Holds[0].Constraint)
+		}
+	}
+
+	if _, err = soln.(RetainedSolution).WhyNotNewer("nope", sm); err == nil {
+		t.Error("expected an error for a project not in the solution")
+	}
+
+	// Under MVS, nothing but the constraints determines the version.
+	params.MVS = true
+	params.Lock = nil
+	params.Deny = nil
+	s, err = Prepare(params, sm)
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+	soln, err = s.Solve()
+	if err != nil {
+		t.Fatalf("unexpected error while solving with MVS: %s", err)
+	}
+
+	r, err := soln.(RetainedSolution).WhyNotNewer("c", sm)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(r.Holds) != 1 || r.Holds[0].Kind != HeldByMVS {
+		t.Errorf("expected c to be held by MVS, got:\n%s", r)
+	}
+}
@@ -0,0 +1,229 @@
+package gps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sdboyer/gps/pkgtree"
+)
+
+// A WorkspaceRoot is a root project to be solved together with the one
+// described by the rest of the SolveParameters, as part of a single
+// workspace.
+type WorkspaceRoot struct {
+	// Dir is the path to the root of the project. A real path to a readable
+	// directory is required.
+	Dir string
+
+	// PackageTree is the tree of packages that comprise the project, as well
+	// as the import path that identifies the root of that tree. As with
+	// RootPackageTree, the ImportRoot must be non-empty, and at least one
+	// package must be present.
+	PackageTree pkgtree.PackageTree
+
+	// Manifest is the project's root manifest. Its constraints and overrides
+	// apply to the whole workspace, while its ignored and required packages
+	// apply only to the project's own packages.
+	//
+	// May be nil.
+	Manifest RootManifest
+}
+
+// localRoot is one of the root projects in the workspace being solved.
+type localRoot struct {
+	dir string
+	rpt pkgtree.PackageTree
+	rm  SimpleManifest
+	// The packages this root ignores and requires.
+	ig, req map[string]bool
+}
+
+// prepWorkspace records the root project, along with any other roots in the
+// workspace, as the local roots, then combines the constraints, overrides,
+// ignores and requires of all the roots.
+func (rd *rootdata) prepWorkspace(params SolveParameters) error {
+	rd.ws = []localRoot{{
+		dir: rd.dir,
+		rpt: rd.rpt,
+		rm:  rd.rm,
+		ig:  rd.ig,
+		req: rd.req,
+	}}
+	if len(params.Workspace) == 0 {
+		return nil
+	}
+
+	// Don't modify the root manifest's own overrides.
+	ovr := make(ProjectConstraints, len(rd.ovr))
+	for pr, pp := range rd.ovr {
+		ovr[pr] = pp
+	}
+	rd.ovr = ovr
+
+	for _, w := range params.Workspace {
+		if w.Dir == "" {
+			return badOptsFailure("workspace roots must specify a non-empty directory")
+		}
+		ir := w.PackageTree.ImportRoot
+		if ir == "" {
+			return badOptsFailure(fmt.Sprintf("workspace root at %s must include a non-empty import root", w.Dir))
+		}
+		if len(w.PackageTree.Packages) == 0 {
+			return badOptsFailure(fmt.Sprintf("at least one package must be present in the PackageTree for workspace root %s", ir))
+		}
+		for _, lr := range rd.ws {
+			if underRoot(lr.rpt.ImportRoot, ir) || underRoot(ir, lr.rpt.ImportRoot) {
+				return badOptsFailure(fmt.Sprintf("workspace roots %s and %s overlap", lr.rpt.ImportRoot, ir))
+			}
+		}
+
+		m := w.Manifest
+		if m == nil {
+			m = simpleRootManifest{}
+		}
+		lr := localRoot{
+			dir: w.Dir,
+			rpt: w.PackageTree.Copy(),
+			rm:  prepManifest(m),
+			ig:  m.IgnoredPackages(),
+			req: m.RequiredPackages(),
+		}
+		if lr.ig == nil {
+			lr.ig = make(map[string]bool)
+		}
+		if lr.req == nil {
+			lr.req = make(map[string]bool)
+		}
+		for pkg := range lr.req {
+			if lr.ig[pkg] {
+				return badOptsFailure(fmt.Sprintf("%q was given as both a required and ignored package in workspace root %s", pkg, ir))
+			}
+		}
+
+		for pr, pp := range m.Overrides() {
+			if pp.Constraint == nil && pp.Source == "" {
+				return badOptsFailure(fmt.Sprintf("An override was declared for %s in workspace root %s, but without any non-zero properties", pr, ir))
+			}
+			if epp, has := rd.ovr[pr]; has && !sameProperties(epp, pp) {
+				return badOptsFailure(fmt.Sprintf("workspace root %s overrides %s differently than another root", ir, pr))
+			}
+			rd.ovr[pr] = pp
+		}
+
+		rd.ws = append(rd.ws, lr)
+	}
+
+	// Combine the constraints from all the roots, dropping those the roots
+	// place on each other; they're local, so there's nothing to solve for.
+	var deps, tdeps []ProjectConstraints
+	for _, lr := range rd.ws {
+		deps = append(deps, lr.rm.Deps)
+		tdeps = append(tdeps, lr.rm.TestDeps)
+	}
+	var err error
+	rd.rm = SimpleManifest{}
+	if rd.rm.Deps, err = rd.mergeRootConstraints(deps); err != nil {
+		return err
+	}
+	if rd.rm.TestDeps, err = rd.mergeRootConstraints(tdeps); err != nil {
+		return err
+	}
+
+	// Outside the roots, a package is only ignored if every root ignores it,
+	// as any root that doesn't may need it. Requires are combined.
+	rd.ig = make(map[string]bool)
+	for pkg := range rd.ws[0].ig {
+		all := true
+		for _, lr := range rd.ws[1:] {
+			all = all && lr.ig[pkg]
+		}
+		if all {
+			rd.ig[pkg] = true
+		}
+	}
+	rd.req = make(map[string]bool)
+	for _, lr := range rd.ws {
+		for pkg := range lr.req {
+			rd.req[pkg] = true
+		}
+	}
+
+	return nil
+}
+
+// mergeRootConstraints intersects the constraints each root places on the
+// same project, dropping any on the roots themselves.
+func (rd rootdata) mergeRootConstraints(pcs []ProjectConstraints) (ProjectConstraints, error) {
+	out := make(ProjectConstraints)
+	for _, pc := range pcs {
+		for pr, pp := range pc {
+			if rd.isRoot(pr) {
+				continue
+			}
+			if epp, has := out[pr]; has && epp.Source != "" && pp.Source != "" && epp.Source != pp.Source {
+				return nil, badOptsFailure(fmt.Sprintf("workspace roots disagree on the source for %s: %s and %s", pr, epp.Source, pp.Source))
+			}
+		}
+		out = out.merge(pc)
+	}
+
+	for pr := range out {
+		if rd.isRoot(pr) {
+			delete(out, pr)
+		}
+	}
+	return out, nil
+}
+
+func sameProperties(pp1, pp2 ProjectProperties) bool {
+	if pp1.Source != pp2.Source {
+		return false
+	}
+	if pp1.Constraint == nil || pp2.Constraint == nil {
+		return pp1.Constraint == pp2.Constraint
+	}
+	return pp1.Constraint.typedString() == pp2.Constraint.typedString()
+}
+
+// localRoot returns the workspace root with the given import root, if there
+// is one.
+func (rd rootdata) localRoot(pr ProjectRoot) (localRoot, bool) {
+	for _, lr := range rd.ws {
+		if pr == ProjectRoot(lr.rpt.ImportRoot) {
+			return lr, true
+		}
+	}
+	return localRoot{}, false
+}
+
+// isLocal indicates whether the import path is within any of the workspace
+// roots.
+func (rd rootdata) isLocal(path string) bool {
+	for _, lr := range rd.ws {
+		if underRoot(lr.rpt.ImportRoot, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceRoots returns the import roots of all the workspace roots other
+// than the root project, sorted.
+func (rd rootdata) workspaceRoots() []string {
+	if len(rd.ws) < 2 {
+		return nil
+	}
+
+	roots := make([]string, 0, len(rd.ws)-1)
+	for _, lr := range rd.ws[1:] {
+		roots = append(roots, lr.rpt.ImportRoot)
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// underRoot indicates whether the import path is the root, or within it.
+func underRoot(root, path string) bool {
+	return strings.HasPrefix(path, root) && isPathPrefixOrEqual(root, path)
+}
@@ -0,0 +1,116 @@
+package gps
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSolveWorkspace(t *testing.T) {
+	fix := bimodalFixture{
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0", "a ^1.0.0"),
+				pkg("root", "a", "svc/lib", "c"),
+			),
+			// Another root in the workspace, rather than a dependency.
+			dsp(mkDepspec("svc 0.0.0", "a <1.2.0", "b *", "root *"),
+				pkg("svc", "b", "c", "root"),
+				pkg("svc/lib", "a"),
+			),
+			dsp(mkDepspec("a 1.0.0"), pkg("a")),
+			dsp(mkDepspec("a 1.1.0"), pkg("a")),
+			dsp(mkDepspec("a 1.2.0"), pkg("a")),
+			dsp(mkDepspec("b 1.0.0"), pkg("b")),
+			dsp(mkDepspec("c 1.0.0"), pkg("c")),
+		},
+		// The root ignores c, but svc still needs it.
+		ignore: []string{"c"},
+	}
+	svc := bimodalFixture{ds: fix.ds[1:2]}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		Workspace: []WorkspaceRoot{{
+			Dir:         string(svc.ds[0].n),
+			PackageTree: svc.rootTree(),
+			Manifest:    svc.rootmanifest(),
+		}},
+		Retain: true,
+	}
+
+	s, err := Prepare(params, newbmSM(fix))
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+	soln, err := s.Solve()
+	if err != nil {
+		t.Fatalf("unexpected error while solving: %s", err)
+	}
+
+	want := map[ProjectRoot]string{"a": "1.1.0", "b": "1.0.0", "c": "1.0.0"}
+	got := make(map[ProjectRoot]string)
+	for _, lp := range soln.Projects() {
+		got[lp.Ident().ProjectRoot] = lp.Version().String()
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %v projects in the lock, got %v", len(want), got)
+	}
+	for pr, v := range want {
+		if got[pr] != v {
+			t.Errorf("expected %s at %s, got %q", pr, v, got[pr])
+		}
+	}
+
+	dr, err := soln.(RetainedSolution).Why("a")
+	if err != nil {
+		t.Fatalf("unexpected error explaining a: %s", err)
+	}
+	if len(dr.ImportChains) != 2 || dr.ImportChains[1][0] != "svc/lib" {
+		t.Errorf("expected a to be reached from both roots, got %v", dr.ImportChains)
+	}
+
+	// The workspace is part of the hashed inputs.
+	single := params
+	single.Workspace = nil
+	s2, err := Prepare(single, newbmSM(fix))
+	if err != nil {
+		t.Fatalf("unexpected error while preparing solver: %s", err)
+	}
+	if bytes.Equal(s.HashInputs(), s2.HashInputs()) {
+		t.Error("expected the workspace to change the input hash")
+	}
+
+	bad := map[string]WorkspaceRoot{
+		"overlapping root": {
+			Dir:         "svc",
+			PackageTree: bimodalFixture{ds: []depspec{dsp(mkDepspec("root/sub 0.0.0"), pkg("root/sub"))}}.rootTree(),
+		},
+		"conflicting override": {
+			Dir:         "svc",
+			PackageTree: svc.rootTree(),
+			Manifest: simpleRootManifest{
+				ovr: ProjectConstraints{"a": ProjectProperties{Constraint: mkSVC("1.0.0")}},
+			},
+		},
+		"conflicting source": {
+			Dir:         "svc",
+			PackageTree: svc.rootTree(),
+			Manifest: simpleRootManifest{
+				c: ProjectConstraints{"a": ProjectProperties{Source: "elsewhere", Constraint: Any()}},
+			},
+		},
+	}
+	for name, w := range bad {
+		p := params
+		p.Manifest = simpleRootManifest{
+			c:   ProjectConstraints{"a": ProjectProperties{Source: "a", Constraint: Any()}},
+			ovr: ProjectConstraints{"a": ProjectProperties{Constraint: mkSVC("1.1.0")}},
+		}
+		p.Workspace = []WorkspaceRoot{w}
+		if _, err := Prepare(p, newbmSM(fix)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}